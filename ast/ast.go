@@ -23,6 +23,12 @@ type Expression interface {
 	expressionNode()
 }
 
+// Pattern is the target of a destructuring let statement.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
 // Program is the root node of every AST our parser produces.
 type Program struct {
 	Statements []Statement
@@ -30,9 +36,30 @@ type Program struct {
 
 // LetStatement represents a node for variable binding.
 type LetStatement struct {
-	Token token.Token // the token.LET token
+	Token   token.Token // the token.LET token
+	Name    *Identifier
+	Pattern Pattern // set instead of Name when the let destructures its value
+	Value   Expression
+}
+
+// ImportStatement binds the module at Path, resolved relative to the importing file, to Name: import "lib/math.mk" as math
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
 	Name  *Identifier
-	Value Expression
+}
+
+// ArrayPattern binds the elements of an array to names in order, and the elements left over to Rest: [a, b, ...rest]
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []*Identifier
+	Rest     *Identifier // nil when the pattern has no ...rest
+}
+
+// HashPattern binds the values of a hash to the names used as their string keys: {name, age}
+type HashPattern struct {
+	Token token.Token // the '{' token
+	Keys  []*Identifier
 }
 
 // Identifier represents the identifier of the binding, it is a type of Expression.
@@ -47,6 +74,12 @@ type ReturnStatement struct {
 	ReturnValue Expression
 }
 
+// ThrowStatement raises the value of its expression as an error.
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
 // ExpressionStatement is a wrapper and is statement consisting solely of one expression.
 type ExpressionStatement struct {
 	Token      token.Token
@@ -80,6 +113,11 @@ type Boolean struct {
 	Value bool
 }
 
+// NullLiteral represents the null literal.
+type NullLiteral struct {
+	Token token.Token
+}
+
 // IfExpression represents an if-else conditional expression in the abstract syntax tree.
 type IfExpression struct {
 	Token       token.Token
@@ -88,6 +126,32 @@ type IfExpression struct {
 	Alternative *BlockStatement
 }
 
+// MatchExpression compares Subject with the pattern of each arm in turn and evaluates to the body of the first
+// arm whose pattern is equal to it, or null when no arm matches.
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+// MatchArm is a single `pattern => body` arm of a MatchExpression. A nil Pattern is the `_` wildcard, which matches anything.
+// Body is an Expression, or a *BlockStatement when it is written in braces, as the blocks of an if expression are; a
+// hash literal body needs parentheses, as in `1 => ({"a": 1})`.
+type MatchArm struct {
+	Pattern Expression
+	Body    Node
+}
+
+// TryExpression runs Block, handing an error raised inside it to Catch with the error bound to Param.
+// Finally, when present, runs last whether or not an error was raised. Either Catch or Finally may be nil, not both.
+type TryExpression struct {
+	Token   token.Token // the 'try' token
+	Block   *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
 // BlockStatement represents a block of statements enclosed within braces.
 type BlockStatement struct {
 	Token      token.Token
@@ -100,10 +164,20 @@ type StringLiteral struct {
 	Value string
 }
 
+// TemplateLiteral represents a template string: `text${expression}text`. The text around the substitutions is kept
+// in Strings, which always holds one more element than Expressions.
+type TemplateLiteral struct {
+	Token       token.Token // the token.TEMPLATE or token.TEMPLATE_HEAD token
+	Strings     []string
+	Expressions []Expression
+}
+
 // FunctionLiteral represents a function definition with parameters and a body.
 type FunctionLiteral struct {
 	Token      token.Token // the 'fn' token
 	Parameters []*Identifier
+	Defaults   []Expression // the default value of each parameter, nil for those without one; empty when there are none
+	Rest       *Identifier  // the ...rest parameter collecting any remaining arguments, if there is one
 	Body       *BlockStatement
 	Name       string
 }
@@ -129,11 +203,20 @@ type IndexExpression struct {
 }
 
 // HashLiteral allows any expression as a key, and any expression as a value.
+// A *SpreadExpression in Keys copies the pairs of another hash in its place and has no entry in Pairs.
 type HashLiteral struct {
-	Token token.Token // The "{" token
+	Token token.Token  // The "{" token
+	Keys  []Expression // the keys of Pairs in source order
 	Pairs map[Expression]Expression
 }
 
+// SpreadExpression expands the elements of an array into call arguments or array elements, or the pairs of a
+// hash into a hash literal: f(...args), [...a, ...b], {...base}
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
 // String creates a buffer and writes the return value of each statement's String() method to it.
 func (p *Program) String() string {
 	var out bytes.Buffer
@@ -153,12 +236,54 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+// Exports returns the names bound by the top-level let statements of the program, in the order they are first bound.
+// These are the bindings a module makes available to the programs that import it.
+func (p *Program) Exports() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name *Identifier) {
+		if !seen[name.Value] {
+			seen[name.Value] = true
+			names = append(names, name.Value)
+		}
+	}
+
+	for _, s := range p.Statements {
+		ls, ok := s.(*LetStatement)
+		if !ok {
+			continue
+		}
+
+		switch pattern := ls.Pattern.(type) {
+		case nil:
+			add(ls.Name)
+		case *ArrayPattern:
+			for _, e := range pattern.Elements {
+				add(e)
+			}
+			if pattern.Rest != nil {
+				add(pattern.Rest)
+			}
+		case *HashPattern:
+			for _, k := range pattern.Keys {
+				add(k)
+			}
+		}
+	}
+
+	return names
+}
+
 // String allows for printing of AST nodes.
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -177,6 +302,18 @@ func (ls *LetStatement) TokenLiteral() string {
 
 func (ls *LetStatement) statementNode() {}
 
+// String allows for printing of AST nodes.
+func (is *ImportStatement) String() string {
+	return fmt.Sprintf("%s %q as %s;", is.TokenLiteral(), is.Path.Value, is.Name.String())
+}
+
+// TokenLiteral returns the Literal from the ImportStatement being called on.
+func (is *ImportStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *ImportStatement) statementNode() {}
+
 // String returns the Value of the Identifier.
 func (i *Identifier) String() string {
 	return i.Value
@@ -281,6 +418,18 @@ func (b *Boolean) TokenLiteral() string {
 
 func (b *Boolean) expressionNode() {}
 
+// String allows for printing of AST nodes.
+func (nl *NullLiteral) String() string {
+	return nl.Token.Literal
+}
+
+// TokenLiteral returns the Literal from the NullLiteral being called on.
+func (nl *NullLiteral) TokenLiteral() string {
+	return nl.Token.Literal
+}
+
+func (nl *NullLiteral) expressionNode() {}
+
 // String allows for printing of AST nodes.
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
@@ -288,7 +437,7 @@ func (ie *IfExpression) String() string {
 	out.WriteString("if")
 	out.WriteString(ie.Condition.String())
 	out.WriteString(" ")
-	out.WriteString(ie.Condition.String())
+	out.WriteString(ie.Consequence.String())
 
 	if ie.Alternative != nil {
 		out.WriteString("else ")
@@ -331,23 +480,45 @@ func (bs *BlockStatement) statementNode() {
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	var params []string
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
 	if fl.Name != "" {
 		out.WriteString(fmt.Sprintf("<%s>", fl.Name))
 	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(FormatParameters(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(")")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// MinArity returns the number of parameters before the first one with a default, which every call has to pass.
+func (fl *FunctionLiteral) MinArity() int {
+	for i, d := range fl.Defaults {
+		if d != nil {
+			return i
+		}
+	}
+	return len(fl.Parameters)
+}
+
+// FormatParameters prints a parameter list, without the parentheses, as it appears in source.
+func FormatParameters(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	var out []string
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			out = append(out, p.String()+" = "+defaults[i].String())
+		} else {
+			out = append(out, p.String())
+		}
+	}
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+
+	return strings.Join(out, ", ")
+}
+
 // TokenLiteral returns the Literal from the FunctionLiteral being called on.
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
@@ -447,8 +618,12 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	var pairs []string
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		if spread, ok := key.(*SpreadExpression); ok {
+			pairs = append(pairs, spread.String())
+			continue
+		}
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -465,3 +640,154 @@ func (hl *HashLiteral) TokenLiteral() string {
 func (hl *HashLiteral) expressionNode() {
 
 }
+
+// String allows for printing of AST nodes.
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// TokenLiteral returns the Literal from the ThrowStatement being called on.
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
+// String allows for printing of AST nodes.
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try {")
+	out.WriteString(te.Block.String())
+	out.WriteString("}")
+
+	if te.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(te.Param.String())
+		out.WriteString(") {")
+		out.WriteString(te.Catch.String())
+		out.WriteString("}")
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally {")
+		out.WriteString(te.Finally.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
+// TokenLiteral returns the Literal from the TryExpression being called on.
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+
+func (te *TryExpression) expressionNode() {}
+
+// String allows for printing of AST nodes.
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := make([]string, len(me.Arms))
+	for i, arm := range me.Arms {
+		pattern := "_"
+		if arm.Pattern != nil {
+			pattern = arm.Pattern.String()
+		}
+		body := arm.Body.String()
+		if _, ok := arm.Body.(*BlockStatement); ok {
+			body = "{" + body + "}"
+		}
+		arms[i] = pattern + " => " + body
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") {")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// TokenLiteral returns the Literal from the MatchExpression being called on.
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+// String allows for printing of AST nodes.
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("`")
+	for i, s := range tl.Strings {
+		out.WriteString(s)
+		if i < len(tl.Expressions) {
+			out.WriteString("${" + tl.Expressions[i].String() + "}")
+		}
+	}
+	out.WriteString("`")
+
+	return out.String()
+}
+
+// TokenLiteral returns the Literal from the TemplateLiteral being called on.
+func (tl *TemplateLiteral) TokenLiteral() string {
+	return tl.Token.Literal
+}
+
+func (tl *TemplateLiteral) expressionNode() {}
+
+// String allows for printing of AST nodes.
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+// TokenLiteral returns the Literal from the SpreadExpression being called on.
+func (se *SpreadExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SpreadExpression) expressionNode() {
+
+}
+
+// String allows for printing of AST nodes.
+func (ap *ArrayPattern) String() string {
+	var elements []string
+	for _, e := range ap.Elements {
+		elements = append(elements, e.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// TokenLiteral returns the Literal from the ArrayPattern being called on.
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+
+func (ap *ArrayPattern) patternNode() {}
+
+// String allows for printing of AST nodes.
+func (hp *HashPattern) String() string {
+	var keys []string
+	for _, k := range hp.Keys {
+		keys = append(keys, k.String())
+	}
+
+	return "{" + strings.Join(keys, ", ") + "}"
+}
+
+// TokenLiteral returns the Literal from the HashPattern being called on.
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+
+func (hp *HashPattern) patternNode() {}
@@ -3,6 +3,7 @@ package ast_test
 import (
 	"monkey/ast"
 	"monkey/token"
+	"reflect"
 	"testing"
 )
 
@@ -36,3 +37,26 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestProgramExports(t *testing.T) {
+	ident := func(name string) *ast.Identifier {
+		return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	let := func(name *ast.Identifier, pattern ast.Pattern) *ast.LetStatement {
+		return &ast.LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: name, Pattern: pattern, Value: ident("v")}
+	}
+
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			let(ident("a"), nil),
+			&ast.ExpressionStatement{Expression: ident("a")},
+			let(nil, &ast.ArrayPattern{Elements: []*ast.Identifier{ident("b")}, Rest: ident("rest")}),
+			let(nil, &ast.HashPattern{Keys: []*ast.Identifier{ident("c"), ident("a")}}),
+		},
+	}
+
+	expected := []string{"a", "b", "rest", "c"}
+	if got := program.Exports(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("program.Exports() wrong. want=%q, got=%q", expected, got)
+	}
+}
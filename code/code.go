@@ -57,9 +57,12 @@ const (
 	OpNotEqual
 
 	// OpGreaterThan instructs the VM to use a greater than comparison.
-	// Note that we do not need a less than operator as 3 < 5 can be re-ordered to 5 > 3.
 	OpGreaterThan
 
+	// OpLessThan instructs the VM to use a less than comparison.
+	// 3 < 5 could be re-ordered to 5 > 3, but keeping the operands in source order lets errors name the operator that was written.
+	OpLessThan
+
 	// OpMinus instructs the VM to negate an integer.
 	OpMinus
 
@@ -105,7 +108,7 @@ const (
 	// OpSetLocal instructs the VM to create a local binding.
 	OpSetLocal
 
-	// OpGetBuiltin allows the VM to detect built-in functions, the operand in this instruction is the index of the referenced function in object.Builtins.
+	// OpGetBuiltin allows the VM to detect built-in functions, the operand in this instruction is the index of the referenced function in the object.BuiltinRegistry.
 	OpGetBuiltin
 
 	// OpClosure is the instruction sent by the compiler to the VM to wrap the *object.CompiledFunction in an *object.Closure.
@@ -116,6 +119,45 @@ const (
 
 	// OpCurrentClosure instructs the VM to load the current closure being executed on to the stack.
 	OpCurrentClosure
+
+	// OpTry registers an error handler on the current frame. The operand is where execution continues, with the
+	// caught error on the stack, when an error is raised before the matching OpEndTry.
+	OpTry
+
+	// OpEndTry removes the handler most recently registered by OpTry on the current frame.
+	OpEndTry
+
+	// OpThrow pops the topmost element off the stack and raises it as an error.
+	OpThrow
+
+	// OpDup pushes the topmost element of the stack again, so that it can be used without being consumed.
+	OpDup
+
+	// OpSlice pops an array and pushes a new array holding its elements from the operand's index on.
+	OpSlice
+
+	// OpJumpArgGiven jumps to its second operand when the call passed an argument for the parameter in its first
+	// operand, skipping the code that computes the parameter's default value.
+	OpJumpArgGiven
+
+	// OpConcatArrays pops the number of arrays in its operand and pushes a new array holding all of their elements in order.
+	// It builds array literals containing a spread, whose size is only known at runtime.
+	OpConcatArrays
+
+	// OpMergeHashes pops the number of hashes in its operand and pushes a new hash holding all of their pairs, later
+	// pairs replacing the values of earlier ones with the same key. It builds hash literals containing a spread.
+	OpMergeHashes
+
+	// OpCallSpread is OpCall for a call containing a spread: the arguments are passed as an array sitting on top of the function.
+	OpCallSpread
+
+	// OpImport pushes the module compiled into the *object.CompiledModule constant at its operand, running the module
+	// the first time it is imported.
+	OpImport
+
+	// OpConcat pops the number of values in its operand and pushes a string joining the Inspect output of each in order.
+	// It builds template literals.
+	OpConcat
 )
 
 var definitions = map[Opcode]*Definition{
@@ -130,6 +172,7 @@ var definitions = map[Opcode]*Definition{
 	OpEqual:          {"OpEqual", []int{}},
 	OpNotEqual:       {"OpNotEqual", []int{}},
 	OpGreaterThan:    {"OpGreaterThan", []int{}},
+	OpLessThan:       {"OpLessThan", []int{}},
 	OpMinus:          {"OpMinus", []int{}},
 	OpBang:           {"OpBang", []int{}},
 	OpJumpNotTruthy:  {"OpJumpNotTruthy", []int{2}},
@@ -145,10 +188,21 @@ var definitions = map[Opcode]*Definition{
 	OpReturn:         {"OpReturn", []int{}},
 	OpGetLocal:       {"OpGetLocal", []int{1}},
 	OpSetLocal:       {"OpSetLocal", []int{1}},
-	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{2}},
 	OpClosure:        {"OpClosure", []int{2, 1}}, // 2 operands, constantIndex (where we can find it in the constant pool) and how many free variables sit on the stack
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}}, // the instruction is self-contained in a single byte
+	OpTry:            {"OpTry", []int{2}},
+	OpEndTry:         {"OpEndTry", []int{}},
+	OpThrow:          {"OpThrow", []int{}},
+	OpDup:            {"OpDup", []int{}},
+	OpSlice:          {"OpSlice", []int{2}},
+	OpJumpArgGiven:   {"OpJumpArgGiven", []int{1, 2}}, // the parameter index and where its default value ends
+	OpConcatArrays:   {"OpConcatArrays", []int{2}},
+	OpMergeHashes:    {"OpMergeHashes", []int{2}},
+	OpCallSpread:     {"OpCallSpread", []int{}},
+	OpImport:         {"OpImport", []int{2}},
+	OpConcat:         {"OpConcat", []int{2}},
 }
 
 // String outputs a readable format of Instructions.
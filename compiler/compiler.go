@@ -9,8 +9,8 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/code"
+	"monkey/module"
 	"monkey/object"
-	"sort"
 )
 
 type EmittedInstruction struct {
@@ -24,12 +24,23 @@ type Compiler struct {
 	symbolTable *SymbolTable
 	scopes      []CompilationScope
 	scopeIndex  int
+	builtins    *object.BuiltinRegistry
+	modules     *modules // shared with the compilers of the modules imported by the program
+	file        string   // the path of the module being compiled, "" for the main program
+}
+
+// modules tracks the modules imported while compiling a program, so that each one is compiled once.
+type modules struct {
+	loader   *module.Loader
+	compiled map[string]int // the constant index of each compiled module by path
+	loading  []string       // the modules being compiled, outermost first
 }
 
 // Bytecode represents the compiled output, containing instructions and a set of constants used during execution.
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	Builtins     *object.BuiltinRegistry // the registry OpGetBuiltin operands index into
 }
 
 // CompilationScope represents an isolated compilation context for a single scope (e.g. the top-level program or a function body).
@@ -37,35 +48,53 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	tries               []tryBlock // the try expressions enclosing the code being compiled, innermost last
+}
+
+// tryBlock is part of a try expression that a return statement can leave early.
+// The return has to remove the part's handler, if it registered one, and run the finally block on its way out.
+type tryBlock struct {
+	hasHandler bool
+	finally    *ast.BlockStatement
 }
 
 // New  returns a new instance of Compiler with initialized instructions and constants.
 func New() *Compiler {
+	return NewWithBuiltins(object.NewBuiltinRegistry())
+}
+
+// NewWithBuiltins returns a new instance of Compiler that resolves builtins from the given registry instead of the defaults.
+func NewWithBuiltins(builtins *object.BuiltinRegistry) *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
-	symbolTable := NewSymbolTable()
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
-	}
 	return &Compiler{
 		constants:   []object.Object{},
-		symbolTable: symbolTable,
+		symbolTable: NewSymbolTableWithBuiltins(builtins),
 		scopeIndex:  0,
 		scopes:      []CompilationScope{mainScope},
+		builtins:    builtins,
 	}
 }
 
 // NewWithState  returns a new instance of Compiler with symbol table and constants to keep global state for the REPL.
-func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
-	compiler := New()
+// The symbol table must have been created from the same builtin registry.
+func NewWithState(s *SymbolTable, constants []object.Object, builtins *object.BuiltinRegistry) *Compiler {
+	compiler := NewWithBuiltins(builtins)
 	compiler.symbolTable = s
 	compiler.constants = constants
 	return compiler
 }
 
+// SetLoader lets the program import modules read by loader. file is the path of the program within the loader's
+// file system, which imports are resolved relative to; it is "" for a program that was not read from a file.
+func (c *Compiler) SetLoader(loader *module.Loader, file string) {
+	c.modules = &modules{loader: loader, compiled: make(map[string]int)}
+	c.file = file
+}
+
 // Compile recursively traverses an AST node, generates bytecode instructions, and appends constants to the compiler's state.
 func (c *Compiler) Compile(node ast.Node) error {
 	switch node := node.(type) {
@@ -85,19 +114,6 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.InfixExpression:
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
-			c.emit(code.OpGreaterThan)
-			return nil
-		}
-
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
@@ -119,6 +135,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpDiv)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
@@ -140,6 +158,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpFalse)
 		}
 
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+
 	case *ast.PrefixExpression:
 		err := c.Compile(node.Right)
 		if err != nil {
@@ -194,6 +215,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		afterAlternativePos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, afterAlternativePos)
 
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(node)
+
 	case *ast.BlockStatement:
 		for _, s := range node.Statements {
 			err := c.Compile(s)
@@ -203,22 +227,35 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.LetStatement:
+		if node.Pattern != nil {
+			return c.compileDestructuring(node.Pattern, node.Value)
+		}
+
 		symbol := c.symbolTable.Define(node.Name.Value)
 		if err := c.Compile(node.Value); err != nil {
 			return err
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
+		c.storeSymbol(symbol)
+
+	case *ast.ImportStatement:
+		if mod, ok := c.builtins.Module(node.Path.Value); ok {
+			// native modules have nothing to run, so they are loaded like any other constant.
+			c.emit(code.OpConstant, c.addConstant(mod))
 		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
+			moduleIndex, err := c.compileModule(node.Path.Value)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpImport, moduleIndex)
 		}
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
 
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
 			// compile-time error
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return fmt.Errorf("identifier not found: %s", node.Value)
 		}
 
 		c.loadSymbol(symbol)
@@ -227,7 +264,28 @@ func (c *Compiler) Compile(node ast.Node) error {
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
 
+	case *ast.TemplateLiteral:
+		// empty text between substitutions adds nothing to the result, so it is left out.
+		parts := 0
+		for i, s := range node.Strings {
+			if s != "" {
+				c.emit(code.OpConstant, c.addConstant(&object.String{Value: s}))
+				parts++
+			}
+			if i < len(node.Expressions) {
+				if err := c.Compile(node.Expressions[i]); err != nil {
+					return err
+				}
+				parts++
+			}
+		}
+		c.emit(code.OpConcat, parts)
+
 	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			return c.compileSpreadElements(node.Elements)
+		}
+
 		for _, element := range node.Elements {
 			err := c.Compile(element)
 			if err != nil {
@@ -237,15 +295,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteral:
-		var keys []ast.Expression
-		for k := range node.Pairs {
-			keys = append(keys, k)
+		if hasSpread(node.Keys) {
+			return c.compileSpreadHash(node)
 		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
 
-		for _, k := range keys {
+		for _, k := range node.Keys {
 			err := c.Compile(k)
 			if err != nil {
 				return err
@@ -277,8 +331,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.Name != "" {
 			c.symbolTable.DefineFunctionName(node.Name)
 		}
-		for _, parameter := range node.Parameters {
-			c.symbolTable.Define(parameter.Value)
+		if err := c.compileParameters(node); err != nil {
+			return err
 		}
 		if err := c.Compile(node.Body); err != nil {
 			return err
@@ -303,6 +357,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  ins,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			MinParameters: node.MinArity(),
+			Variadic:      node.Rest != nil,
+			Name:          node.Name,
 		}
 
 		fnIndex := c.addConstant(compiledFn)
@@ -312,13 +369,33 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err := c.Compile(node.ReturnValue); err != nil {
 			return err
 		}
+		if err := c.leaveTryBlocks(); err != nil {
+			return err
+		}
 
 		c.emit(code.OpReturnValue)
 
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		c.emit(code.OpThrow)
+
 	case *ast.CallExpression:
 		if err := c.Compile(node.Function); err != nil {
 			return err
 		}
+		if hasSpread(node.Args) {
+			if err := c.compileSpreadElements(node.Args); err != nil {
+				return err
+			}
+			c.emit(code.OpCallSpread)
+			return nil
+		}
 		for _, arg := range node.Args {
 			if err := c.Compile(arg); err != nil {
 				return err
@@ -330,11 +407,401 @@ func (c *Compiler) Compile(node ast.Node) error {
 	return nil
 }
 
+// compileParameters defines the parameters of a function and emits the prologue that fills in the ones a call left
+// out, in order:
+//
+//	OpJumpArgGiven <parameter> next
+//	<default>, OpSetLocal <parameter>
+//	next:     ...for each parameter with a default
+//
+// Each parameter is defined once the defaults before it are compiled, so a default can refer to the parameters
+// before it but not to itself or the ones after it, which have no value yet. The rest parameter comes last.
+func (c *Compiler) compileParameters(node *ast.FunctionLiteral) error {
+	for i, parameter := range node.Parameters {
+		if i < len(node.Defaults) && node.Defaults[i] != nil {
+			jumpPos := c.emit(code.OpJumpArgGiven, i, 9999)
+			if err := c.Compile(node.Defaults[i]); err != nil {
+				return err
+			}
+			c.emit(code.OpSetLocal, i)
+
+			c.replaceInstruction(jumpPos, code.Make(code.OpJumpArgGiven, i, len(c.currentInstructions())))
+		}
+		c.symbolTable.Define(parameter.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+	return nil
+}
+
+// compileModule compiles the module imported as spec into a *object.CompiledModule constant, the first time it is
+// imported, and returns the constant's index. The module gets a compiler of its own, so that its global bindings
+// do not mix with those of the importing code, but adds its constants to the same pool.
+func (c *Compiler) compileModule(spec string) (int, error) {
+	if c.modules == nil {
+		return 0, fmt.Errorf("cannot import %s: no module loader", spec)
+	}
+
+	path, err := c.modules.loader.Resolve(c.file, spec)
+	if err != nil {
+		return 0, err
+	}
+	if index, ok := c.modules.compiled[path]; ok {
+		return index, nil
+	}
+	if err := module.CheckCycle(c.modules.loading, path); err != nil {
+		return 0, err
+	}
+
+	program, err := c.modules.loader.Load(path)
+	if err != nil {
+		return 0, err
+	}
+
+	mc := NewWithState(NewSymbolTableWithBuiltins(c.builtins), c.constants, c.builtins)
+	mc.modules = c.modules
+	mc.file = path
+
+	c.modules.loading = append(c.modules.loading, path)
+	err = mc.Compile(program)
+	c.modules.loading = c.modules.loading[:len(c.modules.loading)-1]
+	if err != nil {
+		return 0, err
+	}
+	c.constants = mc.constants
+
+	compiled := &object.CompiledModule{
+		Name:         path,
+		Instructions: mc.currentInstructions(),
+		NumGlobals:   mc.symbolTable.numDefinitions,
+	}
+	for _, name := range program.Exports() {
+		symbol, _ := mc.symbolTable.Resolve(name)
+		compiled.Exports = append(compiled.Exports, object.ModuleExport{Name: name, Index: symbol.Index})
+	}
+
+	index := c.addConstant(compiled)
+	c.modules.compiled[path] = index
+	return index, nil
+}
+
+// hasSpread reports whether any of exps is a spread.
+func hasSpread(exps []ast.Expression) bool {
+	for _, e := range exps {
+		if _, ok := e.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSpreadElements builds an array from elements containing a spread, by concatenating each spread value with
+// arrays of the elements between them:
+//
+//	<element>, <element>, OpArray 2   ...for each run of elements that are not spread
+//	<spread value>                    ...for each spread
+//	OpConcatArrays <number of parts>
+func (c *Compiler) compileSpreadElements(elements []ast.Expression) error {
+	parts, run := 0, 0
+	endRun := func() {
+		if run > 0 {
+			c.emit(code.OpArray, run)
+			parts++
+			run = 0
+		}
+	}
+
+	for _, element := range elements {
+		if spread, ok := element.(*ast.SpreadExpression); ok {
+			endRun()
+			if err := c.Compile(spread.Value); err != nil {
+				return err
+			}
+			parts++
+			continue
+		}
+
+		if err := c.Compile(element); err != nil {
+			return err
+		}
+		run++
+	}
+	endRun()
+
+	c.emit(code.OpConcatArrays, parts)
+	return nil
+}
+
+// compileSpreadHash builds a hash literal containing a spread the same way compileSpreadElements builds an array,
+// merging each spread value with hashes of the pairs between them using OpHash and OpMergeHashes.
+func (c *Compiler) compileSpreadHash(node *ast.HashLiteral) error {
+	parts, run := 0, 0
+	endRun := func() {
+		if run > 0 {
+			c.emit(code.OpHash, run*2)
+			parts++
+			run = 0
+		}
+	}
+
+	for _, k := range node.Keys {
+		if spread, ok := k.(*ast.SpreadExpression); ok {
+			endRun()
+			if err := c.Compile(spread.Value); err != nil {
+				return err
+			}
+			parts++
+			continue
+		}
+
+		if err := c.Compile(k); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Pairs[k]); err != nil {
+			return err
+		}
+		run++
+	}
+	endRun()
+
+	c.emit(code.OpMergeHashes, parts)
+	return nil
+}
+
+// compileDestructuring lowers a destructuring let into one binding per name. Each name is bound to the value
+// indexed by its position or, in a hash pattern, by its name, just as an index expression would:
+//
+//	<value>
+//	OpDup, OpConstant <index or key>, OpIndex, OpSetGlobal/OpSetLocal <name>   ...for each name
+//	OpSlice <number of names>, OpSetGlobal/OpSetLocal <rest>                  ...or OpPop without a rest
+//
+// The names are only defined once the value has been compiled, so the value cannot refer to them.
+func (c *Compiler) compileDestructuring(pattern ast.Pattern, value ast.Expression) error {
+	if err := c.Compile(value); err != nil {
+		return err
+	}
+
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		for i, name := range pattern.Elements {
+			c.emit(code.OpDup)
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(i)}))
+			c.emit(code.OpIndex)
+			c.storeSymbol(c.symbolTable.Define(name.Value))
+		}
+
+		if pattern.Rest != nil {
+			c.emit(code.OpSlice, len(pattern.Elements))
+			c.storeSymbol(c.symbolTable.Define(pattern.Rest.Value))
+			return nil
+		}
+
+	case *ast.HashPattern:
+		for _, key := range pattern.Keys {
+			c.emit(code.OpDup)
+			c.emit(code.OpConstant, c.addConstant(&object.String{Value: key.Value}))
+			c.emit(code.OpIndex)
+			c.storeSymbol(c.symbolTable.Define(key.Value))
+		}
+
+	default:
+		return fmt.Errorf("unknown pattern %T", pattern)
+	}
+
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileMatchExpression lays out a match expression as a sequence of tests, each of which compares a copy of the
+// subject with an arm's pattern and jumps to the next test when they are not equal:
+//
+//	<subject>
+//	OpDup, <pattern>, OpEqual
+//	OpJumpNotTruthy next
+//	OpPop, <body>
+//	OpJump end
+//	next:     ...the following arms; a wildcard arm has no test
+//	OpPop, OpNull
+//	end:
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	if err := c.Compile(node.Subject); err != nil {
+		return err
+	}
+
+	var endJumps []int
+	for _, arm := range node.Arms {
+		nextJump := -1
+		if arm.Pattern != nil {
+			c.emit(code.OpDup)
+			if err := c.Compile(arm.Pattern); err != nil {
+				return err
+			}
+			c.emit(code.OpEqual)
+			nextJump = c.emit(code.OpJumpNotTruthy, 9999)
+		}
+
+		c.emit(code.OpPop)
+		if err := c.compileMatchArmBody(arm.Body); err != nil {
+			return err
+		}
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+		if nextJump != -1 {
+			c.changeOperand(nextJump, len(c.currentInstructions()))
+		}
+	}
+
+	// no arm matched
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+
+	for _, pos := range endJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileMatchArmBody compiles the body of a match arm so that it leaves its value on the stack.
+func (c *Compiler) compileMatchArmBody(body ast.Node) error {
+	if block, ok := body.(*ast.BlockStatement); ok {
+		return c.compileBlockValue(block)
+	}
+	return c.Compile(body)
+}
+
+// compileTryExpression lays out a try expression as follows, leaving out the parts for a missing catch or finally:
+//
+//	OpTry catch
+//	<block>
+//	OpEndTry
+//	OpJump done
+//	catch:    bind the error to the catch parameter
+//	OpTry rethrow
+//	<catch block>
+//	OpEndTry
+//	done:     <finally>, OpPop
+//	OpJump end
+//	rethrow:  <finally>, OpPop, OpThrow
+//	end:
+//
+// The handler around the catch block only exists so that the finally block also runs when the catch block fails.
+// Without a catch block the first handler goes straight to rethrow.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	tryPos := c.emit(code.OpTry, 9999)
+	if err := c.compileTryBlock(node.Block, true, node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+
+	rethrowPositions := []int{}
+	if node.Catch == nil {
+		rethrowPositions = append(rethrowPositions, tryPos)
+	} else {
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(tryPos, len(c.currentInstructions()))
+
+		// the catch parameter is only visible in the catch block.
+		param, endScope := c.symbolTable.DefineScoped(node.Param.Value)
+		c.storeSymbol(param)
+
+		hasFinally := node.Finally != nil
+		if hasFinally {
+			rethrowPositions = append(rethrowPositions, c.emit(code.OpTry, 9999))
+		}
+		if err := c.compileTryBlock(node.Catch, hasFinally, node.Finally); err != nil {
+			return err
+		}
+		endScope()
+		if hasFinally {
+			c.emit(code.OpEndTry)
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	if node.Finally == nil {
+		return nil
+	}
+
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	for _, pos := range rethrowPositions {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpThrow)
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileTryBlock compiles the try or catch block of a try expression so that it leaves its value on the stack,
+// with the finally block to run should a return leave the block early.
+func (c *Compiler) compileTryBlock(block *ast.BlockStatement, hasHandler bool, finally *ast.BlockStatement) error {
+	scope := c.currentScope()
+	scope.tries = append(scope.tries, tryBlock{hasHandler: hasHandler, finally: finally})
+	err := c.compileBlockValue(block)
+	scope = c.currentScope()
+	scope.tries = scope.tries[:len(scope.tries)-1]
+	return err
+}
+
+// compileFinally compiles a finally block, whose value is thrown away.
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if err := c.compileBlockValue(finally); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileBlockValue compiles block so that it leaves its value on the stack, or null when it does not end in an expression.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// leaveTryBlocks emits what a return has to do before leaving the try expressions it is inside of: remove their
+// handlers and run their finally blocks, innermost first.
+func (c *Compiler) leaveTryBlocks() error {
+	tries := c.currentScope().tries
+	defer func() { c.currentScope().tries = tries }()
+
+	for i := len(tries) - 1; i >= 0; i-- {
+		if tries[i].hasHandler {
+			c.emit(code.OpEndTry)
+		}
+		if tries[i].finally != nil {
+			// a return inside the finally block only has the outer try expressions left to leave.
+			c.currentScope().tries = tries[:i:i]
+			if err := c.compileFinally(tries[i].finally); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Bytecode returns the compiled output containing bytecode instructions and constants used during interpretation.
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		Builtins:     c.builtins,
 	}
 }
 
@@ -448,6 +915,15 @@ func (c *Compiler) replaceLastPopWithReturn() {
 	c.currentScope().lastInstruction.Opcode = code.OpReturnValue
 }
 
+// storeSymbol emits the instruction that pops the topmost element off the stack into the binding for s.
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
 func (c *Compiler) loadSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
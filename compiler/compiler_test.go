@@ -6,9 +6,12 @@ import (
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"reflect"
 	"testing"
+	"testing/fstest"
 )
 
 type compilerTestCase struct {
@@ -104,6 +107,14 @@ func TestBooleanExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "null",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1 > 2",
 			expectedConstants: []any{1, 2},
@@ -116,11 +127,11 @@ func TestBooleanExpressions(t *testing.T) {
 		},
 		{
 			input:             "1 < 2",
-			expectedConstants: []any{2, 1},
+			expectedConstants: []any{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
 				code.Make(code.OpPop),
 			},
 		},
@@ -233,6 +244,134 @@ func TestConditionals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `match (1) { 2 => 3, _ => 4 }`,
+			expectedConstants: []any{1, 2, 3, 4},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpDup),
+				// 0004
+				code.Make(code.OpConstant, 1),
+				// 0007
+				code.Make(code.OpEqual),
+				// 0008
+				code.Make(code.OpJumpNotTruthy, 18),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 2),
+				// 0015
+				code.Make(code.OpJump, 27),
+				// 0018
+				code.Make(code.OpPop),
+				// 0019
+				code.Make(code.OpConstant, 3),
+				// 0022
+				code.Make(code.OpJump, 27),
+				// 0025
+				code.Make(code.OpPop),
+				// 0026
+				code.Make(code.OpNull),
+				// 0027
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `if (true) { 1 } else if (false) { 2 }`,
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 21),
+				// 0010
+				code.Make(code.OpFalse),
+				// 0011
+				code.Make(code.OpJumpNotTruthy, 20),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpJump, 21),
+				// 0020
+				code.Make(code.OpNull),
+				// 0021
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `try { 1 } catch (e) { e }`,
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 10),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpEndTry),
+				// 0007
+				code.Make(code.OpJump, 16),
+				// 0010
+				code.Make(code.OpSetGlobal, 0),
+				// 0013
+				code.Make(code.OpGetGlobal, 0),
+				// 0016
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `try { 1 } finally { 2 }`,
+			expectedConstants: []any{1, 2, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 14),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpEndTry),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpPop),
+				// 0011
+				code.Make(code.OpJump, 19),
+				// 0014
+				code.Make(code.OpConstant, 2),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpThrow),
+				// 0019
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `throw "boom"`,
+			expectedConstants: []any{"boom"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpThrow),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestGlobalLetStatements(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -282,6 +421,62 @@ func TestGlobalLetStatements(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `let [a, ...r] = [1, 2];`,
+			expectedConstants: []any{1, 2, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpSlice, 1),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			input:             `let x = {}; let {a} = x;`,
+			expectedConstants: []any{"a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpIndex),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(p) { let [a] = p; a }`,
+			expectedConstants: []any{
+				0,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpDup),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpIndex),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestStringExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		// case 1: make sure the compiler knows how to treat string literals as constants
@@ -308,6 +503,42 @@ func TestStringExpressions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestTemplateLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "`Hello ${1}!`",
+			expectedConstants: []any{"Hello ", 1, "!"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConcat, 3),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// empty text is left out.
+			input:             "`${1}${2}`",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConcat, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "``",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConcat, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
 func TestArrayLiterals(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -396,6 +627,128 @@ func TestHashLiterals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestSpreadExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `let a = []; [1, 2, ...a, 3]`,
+			expectedConstants: []any{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConcatArrays, 3),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let h = {}; {...h, 1: 2}`,
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpMergeHashes, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let f = fn() {}; f(...[])`,
+			expectedConstants: []any{[]code.Instructions{code.Make(code.OpReturn)}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConcatArrays, 1),
+				code.Make(code.OpCallSpread),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestImports(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.mk":     {Data: []byte(`import "lib/math.mk" as m; import "lib/math.mk" as again; m.pi`)},
+		"lib/math.mk": {Data: []byte(`let pi = 3; let [a, b] = [1, 2];`)},
+	}
+	loader := module.NewLoader(fsys)
+	program, err := loader.Load("main.mk")
+	if err != nil {
+		t.Fatalf("loader error: %s", err)
+	}
+
+	c := compiler.New()
+	c.SetLoader(loader, "main.mk")
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := c.Bytecode()
+
+	// the module is compiled once, after the constants it uses itself.
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpImport, 5),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpImport, 5),
+		code.Make(code.OpSetGlobal, 1),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpConstant, 6),
+		code.Make(code.OpIndex),
+		code.Make(code.OpPop),
+	}
+	if err := testInstructions(expectedInstructions, bytecode.Instructions); err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+
+	mod, ok := bytecode.Constants[5].(*object.CompiledModule)
+	if !ok {
+		t.Fatalf("constant 5 is not a module: %T", bytecode.Constants[5])
+	}
+	expectedExports := []object.ModuleExport{{Name: "pi", Index: 0}, {Name: "a", Index: 1}, {Name: "b", Index: 2}}
+	if mod.Name != "lib/math.mk" || mod.NumGlobals != 3 || !reflect.DeepEqual(mod.Exports, expectedExports) {
+		t.Errorf("wrong module. got=%+v", mod)
+	}
+}
+
+func TestNativeImports(t *testing.T) {
+	// native modules need no loader and are loaded as constants.
+	c := compiler.New()
+	if err := c.Compile(parse(`import "math" as m;`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := c.Bytecode()
+
+	expectedInstructions := []code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetGlobal, 0),
+	}
+	if err := testInstructions(expectedInstructions, bytecode.Instructions); err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+
+	if mod, ok := bytecode.Constants[0].(*object.Module); !ok || mod.Name != "math" {
+		t.Errorf("constant 0 is not the math module: %v", bytecode.Constants[0])
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	c := compiler.New()
+	err := c.Compile(parse(`import "lib/math.mk" as m;`))
+	if err == nil || err.Error() != "cannot import lib/math.mk: no module loader" {
+		t.Errorf("wrong error without a loader. got=%v", err)
+	}
+}
+
 func TestIndexExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -502,6 +855,43 @@ func TestFunctions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestDefaultParameters(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn(a, b = 1) { b }`,
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpJumpArgGiven, 1, 9),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(a, ...rest) { rest }`,
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestFunctionCalls(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -1,5 +1,7 @@
 package compiler
 
+import "monkey/object"
+
 // SymbolScope identifies the scope in which a symbol is defined.
 type SymbolScope string
 
@@ -50,6 +52,21 @@ func (st *SymbolTable) Define(name string) Symbol {
 	return sym
 }
 
+// DefineScoped defines name like Define and returns a function that ends the definition's scope, bringing back
+// the symbol it shadowed, if any. The slot of the scoped symbol stays allocated.
+func (st *SymbolTable) DefineScoped(name string) (Symbol, func()) {
+	shadowed, ok := st.store[name]
+	sym := st.Define(name)
+
+	return sym, func() {
+		if ok {
+			st.store[name] = shadowed
+		} else {
+			delete(st.store, name)
+		}
+	}
+}
+
 // Resolve looks up name in the current table, it recursively checks Outer tables if not found.
 func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := st.store[name]
@@ -87,6 +104,17 @@ func NewSymbolTable() *SymbolTable {
 	}
 }
 
+// NewSymbolTableWithBuiltins creates a new top-level symbol table with every builtin in the registry defined.
+func NewSymbolTableWithBuiltins(builtins *object.BuiltinRegistry) *SymbolTable {
+	s := NewSymbolTable()
+	for i, def := range builtins.Definitions() {
+		if def.Builtin != nil {
+			s.DefineBuiltin(i, def.Name)
+		}
+	}
+	return s
+}
+
 // NewEnclosedSymbolTable creates a new symbol table enclosed by outer.
 func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	s := NewSymbolTable()
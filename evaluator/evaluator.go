@@ -3,15 +3,21 @@ package evaluator
 import (
 	"fmt"
 	"monkey/ast"
+	"monkey/module"
 	"monkey/object"
+	"strings"
 )
 
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	NULL  = &object.Null{}
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+	NULL  = object.NULL
 )
 
+// MaxCallDepth is the number of nested function calls allowed before evaluation fails with a stack overflow.
+// It matches vm.MaxFrames so both engines give up on runaway recursion at the same point.
+const MaxCallDepth = 1024
+
 // Eval evaluates a given AST node within a specified environment and returns the resulting object.
 // It handles various node types including programs, expressions, literals, statements, and conditionals.
 func Eval(node ast.Node, env *object.Environment) object.Object {
@@ -24,6 +30,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Integer{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -44,6 +52,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return object.NewThrownError(val)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
@@ -55,7 +73,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Pattern != nil {
+			return bindPattern(node.Pattern, val, env)
+		}
 		env.Set(node.Name.Value, val)
+	case *ast.ImportStatement:
+		mod := evalImport(node.Path.Value, env)
+		if isError(mod) {
+			return mod
+		}
+		env.Set(node.Name.Value, mod)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
@@ -63,8 +90,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		body := node.Body
 		return &object.Function{
 			Parameters: params,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Body:       body,
 			Env:        env,
+			Name:       node.Name,
 		}
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -77,9 +107,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, env)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -104,60 +136,200 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return nil
 }
 
+// evalHashLiteral evaluates a hash literal. With a spread, the runs of pairs between spreads are built into hashes
+// as they are reached and the spread hashes are merged in at the end, in the same order as the VM.
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	var parts []object.Object
+	var run []ast.Expression
+	spread := false
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
-			return key
+	for _, keyNode := range node.Keys {
+		s, ok := keyNode.(*ast.SpreadExpression)
+		if !ok {
+			run = append(run, keyNode)
+			continue
+		}
+		spread = true
+
+		if len(run) > 0 {
+			hash := evalHashPairs(run, node.Pairs, env)
+			if isError(hash) {
+				return hash
+			}
+			parts = append(parts, hash)
+			run = nil
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		value := Eval(s.Value, env)
+		if isError(value) {
+			return value
+		}
+		parts = append(parts, value)
+	}
+
+	if !spread {
+		return evalHashPairs(run, node.Pairs, env)
+	}
+	if len(run) > 0 {
+		hash := evalHashPairs(run, node.Pairs, env)
+		if isError(hash) {
+			return hash
+		}
+		parts = append(parts, hash)
+	}
+
+	merged := object.NewHash()
+	for _, part := range parts {
+		hash, ok := part.(*object.Hash)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError("spread element must be HASH, got %s", part.Type())
+		}
+		for _, pair := range hash.Pairs() {
+			merged.Set(pair.Key.(object.Hashable), pair.Value)
 		}
+	}
+	return merged
+}
 
-		value := Eval(valueNode, env)
+// evalHashPairs builds a hash from the given keys and their values in pairs.
+func evalHashPairs(keys []ast.Expression, pairs map[ast.Expression]ast.Expression, env *object.Environment) object.Object {
+	// every key and value is evaluated before any key is checked, in the same order as the VM.
+	var elements []object.Object
+	for _, keyNode := range keys {
+		key := Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+
+		value := Eval(pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{
-			Key:   key,
-			Value: value,
+		elements = append(elements, key, value)
+	}
+
+	hash := object.NewHash()
+	for i := 0; i < len(elements); i += 2 {
+		hashKey, ok := object.AsHashable(elements[i])
+		if !ok {
+			return newError("unusable as hash key: %s", elements[i].Type())
 		}
+
+		hash.Set(hashKey, elements[i+1])
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return left.(*object.String).Index(index.(*object.Integer).Value)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		value, errObj := left.(*object.Module).Get(index)
+		if errObj != nil {
+			return errObj
+		}
+		return value
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+func evalImport(path string, env *object.Environment) object.Object {
+	if mod, ok := env.Builtins().Module(path); ok {
+		return mod
+	}
+
+	importer := env.Importer()
+	if importer == nil {
+		return newError("cannot import %s: no module loader", path)
+	}
+	return importer.Import(env, path)
+}
+
+// modules tracks the modules imported while evaluating a program, so that each one is evaluated once.
+type modules struct {
+	loader  *module.Loader
+	loaded  map[string]*object.Module
+	loading []string // the modules being evaluated, outermost first
+}
+
+// importer resolves the imports of a single module, or of the main program, against the modules they all share.
+type importer struct {
+	modules *modules
+	file    string
+}
+
+// NewImporter returns an object.Importer that loads modules read by loader. file is the path of the program within
+// the loader's file system, which imports are resolved relative to; it is "" for a program that was not read from a file.
+func NewImporter(loader *module.Loader, file string) object.Importer {
+	return &importer{
+		modules: &modules{loader: loader, loaded: make(map[string]*object.Module)},
+		file:    file,
+	}
+}
+
+// Import implements object.Importer. A module is evaluated the first time it is imported, in an Environment of
+// its own that shares the builtins of env, and exports the bindings of its top-level let statements.
+func (i *importer) Import(env *object.Environment, spec string) object.Object {
+	path, err := i.modules.loader.Resolve(i.file, spec)
+	if err != nil {
+		return newError("%s", err)
+	}
+	if mod, ok := i.modules.loaded[path]; ok {
+		return mod
+	}
+	if err := module.CheckCycle(i.modules.loading, path); err != nil {
+		return newError("%s", err)
+	}
+
+	program, err := i.modules.loader.Load(path)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	moduleEnv := object.NewEnvironmentWithBuiltins(env.Builtins())
+	moduleEnv.SetImporter(&importer{modules: i.modules, file: path})
+
+	i.modules.loading = append(i.modules.loading, path)
+	result := Eval(program, moduleEnv)
+	i.modules.loading = i.modules.loading[:len(i.modules.loading)-1]
+	if errObj, ok := result.(*object.Error); ok {
+		return errObj.Unwind(path)
+	}
+
+	exports := object.NewHash()
+	for _, name := range program.Exports() {
+		if value, ok := moduleEnv.Get(name); ok {
+			exports.Set(&object.String{Value: name}, value)
+		}
+	}
+
+	mod := &object.Module{Name: path, Exports: exports}
+	i.modules.loaded[path] = mod
+	return mod
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObj := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObj.Pairs[key.HashKey()]
+	value, ok := hashObj.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
@@ -172,15 +344,50 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObj.Elements[idx]
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// callContext lets builtins call back into the evaluator from the Environment the builtin was called in.
+type callContext struct {
+	env *object.Environment
+}
+
+// Call implements object.CallContext by applying fn to args.
+func (c callContext) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	result := applyFunction(fn, args, c.env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// Streams implements object.CallContext with the streams of the Environment's builtin registry.
+func (c callContext) Streams() *object.Streams {
+	return c.env.Builtins().Streams()
+}
+
+// applyFunction calls fn with args on behalf of code running in the caller Environment.
+func applyFunction(fn object.Object, args []object.Object, caller *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		minArgs := minArity(fn)
+		if len(args) < minArgs || (len(args) > len(fn.Parameters) && fn.Rest == nil) {
+			return object.NewArityError(minArgs, len(fn.Parameters), fn.Rest != nil, len(args))
+		}
+		// the program itself counts as the first call, like the VM's main frame.
+		if caller.CallDepth()+1 >= MaxCallDepth {
+			return newError("stack overflow")
+		}
+
+		extendedEnv, errObj := extendFunctionEnv(fn, args, caller)
+		if errObj != nil {
+			return errObj.Unwind(fn.Name)
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			return errObj.Unwind(fn.Name)
+		}
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
+		if result := fn.Fn(callContext{env: caller}, args...); result != nil {
 			return result
 		}
 		return NULL
@@ -199,24 +406,104 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+// minArity returns the number of parameters of fn before the first one with a default.
+func minArity(fn *object.Function) int {
+	for i, d := range fn.Defaults {
+		if d != nil {
+			return i
+		}
+	}
+	return len(fn.Parameters)
+}
+
+// extendFunctionEnv binds the parameters of fn to args. Parameters the call left out are bound to their default
+// values in order, so a default can refer to the parameters before it; an error from one is returned instead.
+func extendFunctionEnv(fn *object.Function, args []object.Object, caller *object.Environment) (*object.Environment, *object.Error) {
+	env := object.NewCallEnvironment(fn.Env, caller)
 
 	for i, parameter := range fn.Parameters {
-		env.Set(parameter.Value, args[i])
+		if i < len(args) {
+			env.Set(parameter.Value, args[i])
+			continue
+		}
+
+		value := Eval(fn.Defaults[i], env)
+		if errObj, ok := value.(*object.Error); ok {
+			return nil, errObj
+		}
+		env.Set(parameter.Value, value)
+	}
+
+	// like the VM, a default sees only the parameters before it, so the rest parameter is bound last.
+	if fn.Rest != nil {
+		var rest []object.Object
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
+}
+
+// evalTemplateLiteral joins the text of a template with the Inspect output of each substituted expression.
+func evalTemplateLiteral(node *ast.TemplateLiteral, env *object.Environment) object.Object {
+	var out strings.Builder
+	for i, s := range node.Strings {
+		out.WriteString(s)
+		if i < len(node.Expressions) {
+			evaluated := Eval(node.Expressions[i], env)
+			if isError(evaluated) {
+				return evaluated
+			}
+			out.WriteString(evaluated.Inspect())
+		}
 	}
 
-	return env
+	return &object.String{Value: out.String()}
 }
 
+// evalExpressions evaluates a list of call arguments or array elements, expanding the arrays that are spread into it.
+// Spread values are only checked once every expression has been evaluated, in the same order as the VM.
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
+	var spread []bool
 	for _, e := range exps {
+		s, isSpread := e.(*ast.SpreadExpression)
+		if isSpread {
+			e = s.Value
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
+		spread = append(spread, isSpread)
+	}
+
+	for i := range spread {
+		if spread[i] {
+			return expandSpreads(result, spread)
+		}
+	}
+	return result
+}
+
+// expandSpreads replaces each value marked as spread with the elements of the array it holds.
+func expandSpreads(values []object.Object, spread []bool) []object.Object {
+	var result []object.Object
+	for i, value := range values {
+		if !spread[i] {
+			result = append(result, value)
+			continue
+		}
+
+		array, ok := value.(*object.Array)
+		if !ok {
+			return []object.Object{newError("spread element must be ARRAY, got %s", value.Type())}
+		}
+		result = append(result, array.Elements...)
 	}
 	return result
 }
@@ -229,7 +516,7 @@ func evalIdentifier(
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := env.Builtins().Lookup(node.Value); ok {
 		return builtin
 	}
 	return newError("identifier not found: " + node.Value)
@@ -266,6 +553,95 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
+// bindPattern binds each name in pattern to the value indexed by its position or, in a hash pattern, by its name,
+// just as an index expression would. It returns nil, like a plain let, unless indexing fails.
+func bindPattern(pattern ast.Pattern, val object.Object, env *object.Environment) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		for i, name := range pattern.Elements {
+			element := evalIndexExpression(val, &object.Integer{Value: int64(i)})
+			if isError(element) {
+				return element
+			}
+			env.Set(name.Value, element)
+		}
+
+		if pattern.Rest != nil {
+			array, ok := val.(*object.Array)
+			if !ok {
+				return newError("rest element must be ARRAY, got %s", val.Type())
+			}
+
+			var rest []object.Object
+			if len(pattern.Elements) < len(array.Elements) {
+				rest = append(rest, array.Elements[len(pattern.Elements):]...)
+			}
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+
+	case *ast.HashPattern:
+		for _, key := range pattern.Keys {
+			value := evalIndexExpression(val, &object.String{Value: key.Value})
+			if isError(value) {
+				return value
+			}
+			env.Set(key.Value, value)
+		}
+	}
+
+	return nil
+}
+
+// evalMatchExpression evaluates to the body of the first arm whose pattern is equal to the subject, trying the
+// arms in order, and to NULL when none matches. Patterns after the matching arm are not evaluated.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		if arm.Pattern != nil {
+			pattern := Eval(arm.Pattern, env)
+			if isError(pattern) {
+				return pattern
+			}
+			if !object.Equal(subject, pattern) {
+				continue
+			}
+		}
+
+		// a block body that does not end in an expression has no value.
+		if result := Eval(arm.Body, env); result != nil {
+			return result
+		}
+		return NULL
+	}
+
+	return NULL
+}
+
+// evalTryExpression evaluates a try expression. The finally block runs last in every case, and its own error or
+// return takes over from the try expression's result.
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+	if errObj, ok := result.(*object.Error); ok && te.Catch != nil {
+		result = Eval(te.Catch, object.NewScopedEnvironment(env, te.Param.Value, errObj.ToHash()))
+	}
+
+	if te.Finally != nil {
+		finally := Eval(te.Finally, env)
+		if _, ok := finally.(*object.ReturnValue); ok || isError(finally) {
+			return finally
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -297,9 +673,11 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBooleanObject(left == right)
+		return nativeBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBooleanObject(left != right)
+		return nativeBooleanObject(!object.Equal(left, right))
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return object.RepeatString(left.(*object.String), right.(*object.Integer).Value)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
@@ -310,13 +688,19 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
-
 	leftVal := left.(*object.String).Value
 	RightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + RightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + RightVal}
+	case "<":
+		return nativeBooleanObject(leftVal < RightVal)
+	case ">":
+		return nativeBooleanObject(leftVal > RightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -337,6 +721,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 			Value: leftVal * rightVal,
 		}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{
 			Value: leftVal / rightVal,
 		}
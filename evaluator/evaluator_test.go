@@ -3,9 +3,11 @@ package evaluator_test
 import (
 	"monkey/evaluator"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
 	"testing"
+	"testing/fstest"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -109,6 +111,33 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{
 			"(1 < 2) == false", false,
 		},
+		{
+			`"a" == "a"`, true,
+		},
+		{
+			`"a" != "b"`, true,
+		},
+		{
+			"[1, [2, 3]] == [1, [2, 3]]", true,
+		},
+		{
+			"[1, 2] != [2, 1]", true,
+		},
+		{
+			`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true,
+		},
+		{
+			`{"a": 1} == {"a": 2}`, false,
+		},
+		{
+			"if (false) { 1 } == if (false) { 2 }", true,
+		},
+		{
+			`1 == "1"`, false,
+		},
+		{
+			"let f = fn() { 1 }; [f == f, fn() { 1 } == fn() { 1 }] == [true, false]", true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -117,6 +146,214 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestNullLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"null", nil},
+		{"first([]) == null", true},
+		{"last([]) == null", true},
+		{"rest([]) == null", true},
+		{"tail([]) == null", true},
+		{"if (false) { 1 } == null", true},
+		{`{"a": 1}["b"] == null`, true},
+		{"if (null) { 1 } else { 2 }", 2},
+		{"!null", true},
+		{"null == false", false},
+		{"puts() == null", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	if testEval("null") != object.NULL {
+		t.Errorf("evaluator does not use the shared object.NULL")
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let [a, b] = [1, 2]; a + b`, "3"},
+		{`let [a, ...rest] = [1, 2, 3]; rest`, "[2, 3]"},
+		{`let [...all] = [1, 2]; all`, "[1, 2]"},
+		{`let [a, b, ...rest] = [1]; [b, rest]`, "[null, []]"},
+		{`let {name, age} = {"name": "Ivan", "age": 30}; [name, age]`, "[Ivan, 30]"},
+		{`let {name} = {}; name`, "null"},
+		{`let f = fn(pair) { let [x, y] = pair; x * y }; f([6, 7])`, "42"},
+		{`let [a] = 5; a`, "ERROR: index operator not supported: INTEGER"},
+		{`let {a} = [1]; a`, "ERROR: index operator not supported: ARRAY"},
+		{`let [...r] = {}; r`, "ERROR: rest element must be ARRAY, got HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(x, y = 10) { x + y }; f(1)`, "11"},
+		{`let f = fn(x, y = 10) { x + y }; f(1, 2)`, "3"},
+		{`let f = fn(x, y = x * 2) { y }; f(4)`, "8"},
+		{`let f = fn(a) { fn(b = a) { b } }; f(7)()`, "7"},
+		{`let f = fn(first, ...rest) { rest }; f(1, 2, 3)`, "[2, 3]"},
+		{`let f = fn(x, y = 2, ...rest) { [x, y, rest] }; f(1)`, "[1, 2, []]"},
+		{`fn(x, y = 1) { x }()`, "ERROR: wrong number of arguments: want=1..2, got=0"},
+		{`fn(x, ...r) { x }()`, "ERROR: wrong number of arguments: want=1+, got=0"},
+		{`let f = fn(x = 1 + true) { x }; f()`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`fn(x, y = 1, ...r) { x }`, "fn(x, y = 1, ...r) {\nx\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let add = fn(a, b, c) { a + b + c }; add(1, ...[2, 3])`, "6"},
+		{`let f = fn(...xs) { xs }; f(...[1, 2], ...[3])`, "[1, 2, 3]"},
+		{`len(...["abc"])`, "3"},
+		{`let a = [1, 2]; [0, ...a, 3, ...[]]`, "[0, 1, 2, 3]"},
+		{`let base = {"a": 1, "b": 2}; {...base, "b": 3, "c": 4}`, "{a: 1, b: 3, c: 4}"},
+		{`{...{"a": 1}, ...{"a": 2}}`, "{a: 2}"},
+		{`fn(a) { a }(...[1, 2])`, "ERROR: wrong number of arguments: want=1, got=2"},
+		{`[...1]`, "ERROR: spread element must be ARRAY, got INTEGER"},
+		{`{...[1]}`, "ERROR: spread element must be HASH, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestImports(t *testing.T) {
+	fsys := fstest.MapFS{"lib/math.mk": {Data: []byte(`let square = fn(x) { x * x };`)}}
+
+	env := object.NewEnvironment()
+	env.SetImporter(evaluator.NewImporter(module.NewLoader(fsys), "main.mk"))
+	program := parser.New(lexer.New(`import "lib/math.mk" as math; math.square(3)`)).ParseProgram()
+	testIntegerObject(t, evaluator.Eval(program, env), 9)
+
+	evaluated := testEval(`import "lib/math.mk" as math;`)
+	if evaluated.Inspect() != "ERROR: cannot import lib/math.mk: no module loader" {
+		t.Errorf("wrong result without an importer. got=%q", evaluated.Inspect())
+	}
+}
+
+func TestElseIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"if (1 > 2) { 1 } else if (2 > 1) { 2 } else { 3 }", 2},
+		{"if (1 > 2) { 1 } else if (2 > 3) { 2 } else { 3 }", 3},
+		{"if (1 < 2) { 1 } else if (2 > 1) { 2 }", 1},
+		{"if (false) { 1 } else if (false) { 2 }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (1) { 1 => "one", 2 => "two" }`, "one"},
+		{`match (2) { 1 => "one", 2 => "two", 2 => "again" }`, "two"},
+		{`let f = fn(x) { match (x) { 1 => "int", "a" => "string", true => "bool", _ => "other" } }; [f(1), f("a"), f(true), f(null)]`, "[int, string, bool, other]"},
+		{`match ([1, {"a": 2}]) { [1, {"a": 2}] => 1, _ => 2 }`, "1"},
+		{`let a = 2; match (4) { a + a => "sum", _ => "none" }`, "sum"},
+		{`match (3) { 1 => 2 }`, "null"},
+		{`match (3) { }`, "null"},
+		{`let f = fn(n) { match (n) { 0 => 0, 1 => 1, _ => f(n - 1) + f(n - 2) } }; f(15)`, "610"},
+		{`match (1) { 1 => "one", 1 / 0 => "never" }`, "one"},
+		{`match (2) { 1 / 0 => "never", _ => "other" }`, "ERROR: division by zero"},
+		{`match (1 / 0) { _ => 1 }`, "ERROR: division by zero"},
+		{`match (1) { 1 => { let x = 2; x * 3 }, _ => 0 }`, "6"},
+		{`match (1) { 1 => { let x = 2; } }`, "null"},
+		{`let f = fn() { match (1) { 1 => { return 5; 6 } }; 7 }; f()`, "5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { throw "boom" } catch (e) { e }`, "{message: boom, stack: [], value: boom}"},
+		{`try { 1 / 0 } catch (e) { e }`, "{message: division by zero, stack: [], value: null}"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{`let f = fn() { throw "x" }; let g = fn() { f() }; try { g() } catch (e) { e["stack"] }`, "[f, g]"},
+		{`try { fn() { throw "x" }() } catch (e) { e["stack"] }`, "[<anonymous>]"},
+		{`let f = fn() { throw "x" }; try { try { f() } catch (e) { throw e } } catch (e) { e["stack"] }`, "[f]"},
+		{`try { throw {"message": "m", "code": 7} } catch (e) { e["value"]["code"] }`, "7"},
+		{`let r = fn(n) { r(n + 1) }; try { r(0) } catch (e) { e["message"] }`, "stack overflow"},
+		{`try { map([1], fn(x) { throw x + 1 }) } catch (e) { e["value"] }`, "2"},
+		{`let f = fn() { try { 1 } finally { 2 } }; f()`, "1"},
+		{`try { throw "x" } finally { 2 }`, "ERROR: x"},
+		{`try { try { throw "a" } catch (e) { throw "b" } finally { 1 } } catch (e) { e["message"] }`, "b"},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, "2"},
+		{`try { try { 1 } finally { throw "late" } } catch (e) { e["message"] }`, "late"},
+		{`let f = fn() { try { throw "x" } catch (e) { return 5 }; 6 }; f()`, "5"},
+		{`try { } catch (e) { 1 }`, "null"},
+		{`throw "boom"; 1`, "ERROR: boom"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -297,6 +534,10 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`{[1, fn(x) { x }]: 1}`,
+			"unusable as hash key: ARRAY",
+		},
 	}
 
 	for _, tt := range tests {
@@ -411,6 +652,107 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringOperations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"ab" * 3`, "ababab"},
+		{`"ab" * -1`, "ERROR: negative repeat count: -1"},
+		{`"ab" - "a"`, "ERROR: unknown operator: STRING - STRING"},
+		{`["a" < "b", "b" < "a", "b" > "a", "a" > "a"]`, "[true, false, true, false]"},
+		{`"héllo"[1]`, "é"},
+		{`"abc"[5]`, "null"},
+		{`len("héllo")`, "5"},
+		{`[split("a, b", ", "), join(["a", "b"], "+"), trim(" a "), upper("a"), lower("A"), replace("aa", "a", "b")]`, "[[a, b], a+b, a, A, a, bb]"},
+		{`[starts_with("monkey", "mon"), ends_with("monkey", "mon"), contains("monkey", "key"), contains([1], 1)]`, "[true, false, true, true]"},
+		{`[substr("héllo", 1), substr("héllo", 1, 3), substr("héllo", -2), substr("abc", 2, 1)]`, "[éllo, él, lo, ]"},
+		{`contains("abc", 1)`, "ERROR: argument to `contains` must be STRING, got INTEGER"},
+		{`contains(1, 1)`, "ERROR: argument to `contains` must be ARRAY or STRING, got INTEGER"},
+		{`split("a", 1)`, "ERROR: argument to `split` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`monkey`", "monkey"},
+		{"``", ""},
+		{"let name = \"Jo\"; `Hello ${name}, you are ${30 + 1}: ${[1, true]} ${null}`", "Hello Jo, you are 31: [1, true] null"},
+		{"`a${`b${1}`}c`", "ab1c"},
+		{"`${1 + true}`", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[type(1), type("a"), type(true), type(null), type([]), type({}), type(fn() {}), type(len)]`, "[INTEGER, STRING, BOOLEAN, NULL, ARRAY, HASH, FUNCTION, BUILTIN]"},
+		{`[str(1), str("a"), str([1, "b"]), str(null)]`, "[1, a, [1, b], null]"},
+		{`[int(7), int(" -42 "), int(true), int(false)]`, "[7, -42, 1, 0]"},
+		{`int("4x")`, `ERROR: invalid integer "4x" in base 10`},
+		{`int([])`, "ERROR: cannot convert ARRAY to INTEGER"},
+		{`[bool(0), bool(""), bool(null), bool(false), bool([])]`, "[true, true, false, false, true]"},
+		{`[parse_int("ff", 16), parse_int("-101", 2), parse_int("10")]`, "[255, -5, 10]"},
+		{`parse_int("z", 10)`, `ERROR: invalid integer "z" in base 10`},
+		{`parse_int("99999999999999999999")`, `ERROR: integer "99999999999999999999" is out of range`},
+		{`parse_int("1", 1)`, "ERROR: invalid base 1, must be between 2 and 36"},
+		{`parse_int(1)`, "ERROR: argument to `parse_int` must be STRING, got INTEGER"},
+		{`[is_integer(1), is_string(1), is_boolean(false), is_null(null), is_array([]), is_hash({}), is_function(fn() {}), is_function(len), is_function(1)]`, "[true, false, true, true, true, true, true, true, false]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFormatBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format("%s has %d items (%.2f%%)", "cart", 3, 25)`, "cart has 3 items (25.00%)"},
+		{`format("|%-6s|%6s|%04d|%+d|", "ab", "é", 7, 5)`, "|ab    |     é|0007|+5|"},
+		{`format("%v %s %q %t %x %X %.1s", [1, "a"], null, "hi", true, 255, "hi", "xyz")`, `[1, a] null "hi" true ff 6869 x`},
+		{`format("plain")`, "plain"},
+		{`format("%d", "a")`, "ERROR: format verb %d does not accept STRING"},
+		{`format("%z", 1)`, "ERROR: unknown format verb %z"},
+		{`format("%d %d", 1)`, "ERROR: missing argument for the verb %d"},
+		{`format("%d", 1, 2)`, "ERROR: wrong number of arguments for the format string. got=2, want=1"},
+		{`format("100%")`, "ERROR: format string ends in the middle of the verb %"},
+		{`format(1)`, "ERROR: argument to `format` must be STRING, got INTEGER"},
+		{`print() == null`, "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestBuiltInFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -453,6 +795,153 @@ func TestBuiltInFunctions(t *testing.T) {
 	}
 }
 
+func TestCustomBuiltins(t *testing.T) {
+	builtins := object.NewBuiltinRegistry()
+	builtins.Register("double", func(_ object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	builtins.Remove("len")
+
+	env := object.NewEnvironmentWithBuiltins(builtins)
+	program := parser.New(lexer.New(`let f = fn(x) { double(x) }; f(21)`)).ParseProgram()
+	testIntegerObject(t, evaluator.Eval(program, env), 42)
+
+	program = parser.New(lexer.New(`len([])`)).ParseProgram()
+	errObj, ok := evaluator.Eval(program, env).(*object.Error)
+	if !ok {
+		t.Fatalf("expected removed builtin len to be undefined")
+	}
+	if errObj.Message != "identifier not found: len" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	testIntegerObject(t, testEval(`len([1])`), 1)
+}
+
+func TestBuiltinCallbacks(t *testing.T) {
+	builtins := object.NewBuiltinRegistry()
+	builtins.Register("apply", func(ctx object.CallContext, args ...object.Object) object.Object {
+		result, err := ctx.Call(args[0], args[1:]...)
+		if err != nil {
+			return &object.Error{Message: "apply: " + err.Error()}
+		}
+		return result
+	})
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`apply(fn(a, b) { a * b }, 6, 7)`, 42},
+		{`let add = fn(x) { fn(y) { x + y } }; apply(add(1), 2)`, 3},
+		{`apply(len, [1, 2, 3])`, 3},
+		{`apply(fn(x) { apply(fn(y) { y * 2 }, x) + 1 }, 20)`, 41},
+		{`let fact = fn(n) { if (n == 0) { 1 } else { n * apply(fact, n - 1) } }; fact(5)`, 120},
+		{`apply(fn(a) { a })`, "apply: wrong number of arguments: want=1, got=0"},
+		{`apply(fn() { 1 + true })`, "apply: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := evaluator.Eval(program, object.NewEnvironmentWithBuiltins(builtins))
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not error, got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+		{`map([[1], [1, 2]], len)`, []int{1, 2}},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, []int{3, 4}},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, 16},
+		{`reduce([1, 2, 3], fn(acc, x) { acc * x })`, 6},
+		{`reduce([], fn(acc, x) { acc })`, "`reduce` of empty ARRAY with no initial value"},
+		{`each([1, 2], fn(x) { x })`, nil},
+		{`any([1, 2, 3], fn(x) { x == 2 })`, true},
+		{`all([1, 2, 3], fn(x) { x < 3 })`, false},
+		{`if (all([1, 2], fn(x) { x < 3 })) { 1 } else { 2 }`, 1},
+		{`if (any([1, 2], fn(x) { x > 3 })) { 1 } else { 2 }`, 2},
+		{`find([1, 2, 3, 4], fn(x) { x > 1 })`, 2},
+		{`find([1], fn(x) { x > 1 })`, nil},
+		{`sort([3, 1, 2])`, []int{1, 2, 3}},
+		{`sort([1, "a"])`, "cannot compare STRING and INTEGER"},
+		{`sort_by([3, 1, 2], fn(x) { -x })`, []int{3, 2, 1}},
+		{`map(zip([1, 2, 3], [4, 5]), fn(p) { p[0] * p[1] })`, []int{4, 10}},
+		{`flatten([[1, 2], 3, [4]])`, []int{1, 2, 3, 4}},
+		{`range(2, 5)`, []int{2, 3, 4}},
+		{`range(5, 0, -2)`, []int{5, 3, 1}},
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`slice([1, 2, 3, 4], -3, -1)`, []int{2, 3}},
+		{`index_of(["a", "b"], "b")`, 1},
+		{`contains([1, 2, 3], 3)`, true},
+		{`map([1], fn(x, y) { x })`, "wrong number of arguments: want=2, got=1"},
+		{`filter(1, fn(x) { x })`, "argument to `filter` must be ARRAY, got INTEGER"},
+		{`keys({3: "c", 1: "a", 2: "b"})`, []int{3, 1, 2}},
+		{`values({"b": 2, "a": 1})`, []int{2, 1}},
+		{`map(entries({2: 20, 1: 10}), fn(e) { e[0] + e[1] })`, []int{22, 11}},
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": 1}, "b")`, false},
+		{`has({}, [len])`, "unusable as hash key: ARRAY"},
+		{`keys(delete({1: 1, 2: 2}, 1))`, []int{2}},
+		{`let h = {1: 1}; delete(h, 1); len(keys(h))`, 1},
+		{`merge({"a": 1}, {"a": 2})["a"]`, 2},
+		{`merge({}, [])`, "argument to `merge` must be HASH, got ARRAY"},
+		{`each({1: 2}, fn(k, v) { k + v })`, nil},
+		{`each({1: 2}, fn(k) { k })`, "wrong number of arguments: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong number of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, e := range expected {
+				testIntegerObject(t, array.Elements[i], int64(e))
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not error, got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := `[1, 2 * 2, 3 + 3]`
 
@@ -554,12 +1043,17 @@ func TestHashLiterals(t *testing.T) {
 		evaluator.FALSE.HashKey():                  6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong number of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong number of pairs. got=%d", result.Len())
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	for _, pair := range result.Pairs() {
+		pairs[pair.Key.(object.Hashable).HashKey()] = pair
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := pairs[expectedKey]
 
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
@@ -567,6 +1061,10 @@ func TestHashLiterals(t *testing.T) {
 
 		testIntegerObject(t, pair.Value, expectedValue)
 	}
+
+	if result.Inspect() != "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}" {
+		t.Errorf("pairs are not in source order. got=%q", result.Inspect())
+	}
 }
 
 func TestHashIndexExpressions(t *testing.T) {
@@ -602,6 +1100,18 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`let cache = {[1, 2]: 3}; cache[[1, 2]]`,
+			3,
+		},
+		{
+			`{[1, 2]: 3}[[2, 1]]`,
+			nil,
+		},
+		{
+			`{{"x": 1, "y": 2}: 5}[{"y": 2, "x": 1}]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
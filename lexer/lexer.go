@@ -6,9 +6,10 @@ import "monkey/token"
 // Lexer represents the data to transform.
 type Lexer struct {
 	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	position     int   // current position in input (points to current char)
+	readPosition int   // current reading position in input (after current char)
+	ch           byte  // current char under examination
+	templates    []int // for each template substitution being lexed, the depth of the braces opened inside it
 }
 
 // New returns a new Lexer.
@@ -31,6 +32,11 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -62,9 +68,21 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '{':
+		if len(l.templates) > 0 {
+			l.templates[len(l.templates)-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		if n := len(l.templates); n > 0 && l.templates[n-1] == 0 {
+			// the brace closes a substitution, so the template continues after it.
+			l.templates = l.templates[:n-1]
+			tok = l.readTemplate(token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL)
+		} else {
+			if n > 0 {
+				l.templates[n-1]--
+			}
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -73,9 +91,19 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
+	case '`':
+		tok = l.readTemplate(token.TEMPLATE_HEAD, token.TEMPLATE)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -142,7 +170,7 @@ func (l *Lexer) skipWS() {
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.position >= len(l.input) {
+	if l.readPosition >= len(l.input) {
 		return 0
 	}
 	return l.input[l.readPosition]
@@ -158,3 +186,25 @@ func (l *Lexer) readString() string {
 	}
 	return l.input[pos:l.position]
 }
+
+// readTemplate reads the text of a template literal up to the next substitution, returning it as a token of type
+// substitution, or up to the closing backtick, returning it as a token of type end. A template that is still open at
+// the end of the input is returned as an ILLEGAL token holding the rest of the input.
+func (l *Lexer) readTemplate(substitution, end token.TokenType) token.Token {
+	pos := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == 0 {
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[pos-1 : l.position]}
+		}
+		if l.ch == '`' {
+			return token.Token{Type: end, Literal: l.input[pos:l.position]}
+		}
+		if l.ch == '$' && l.peekChar() == '{' {
+			literal := l.input[pos:l.position]
+			l.readChar()
+			l.templates = append(l.templates, 0)
+			return token.Token{Type: substitution, Literal: literal}
+		}
+	}
+}
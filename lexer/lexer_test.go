@@ -30,6 +30,10 @@ if (5 < 10) {
 "foo bar"
 [1, 2];
 {"foo": "bar"}
+match (x) { _ => 1 }
+[...r] ..
+import "lib/math.mk" as math
+math.pi
 `
 
 	tests := []struct {
@@ -122,6 +126,28 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "_"},
+		{token.ARROW, "=>"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "r"},
+		{token.RBRACKET, "]"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib/math.mk"},
+		{token.IDENT, "as"},
+		{token.IDENT, "math"},
+		{token.IDENT, "math"},
+		{token.DOT, "."},
+		{token.IDENT, "pi"},
 		{token.EOF, ""},
 	}
 
@@ -149,3 +175,80 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTemplateTokens(t *testing.T) {
+	input := "`plain` `a ${x} b ${ {1: `in ${y}`}[1] } c` `${}`"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE, "plain"},
+		{token.TEMPLATE_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.TEMPLATE_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.TEMPLATE_HEAD, "in "},
+		{token.IDENT, "y"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, " c"},
+		{token.TEMPLATE_HEAD, ""},
+		{token.TEMPLATE_TAIL, ""},
+		{token.EOF, ""},
+	}
+
+	l := lexer.New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. Expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func FuzzNextToken(f *testing.F) {
+	f.Add(`let add = fn(x, y) { x + y; }; add(1, 2);`)
+	f.Add(`"unterminated`)
+	f.Add(`{"a": [1, null]} != !-5 <= 10;`)
+	f.Add("\x00\xff@#$")
+	f.Add("`a ${ {b: `${c}`} } d")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := lexer.New(input)
+
+		// every token consumes at least one byte, so EOF must be reached within len(input)+1 tokens.
+		for i := 0; i <= len(input); i++ {
+			if l.NextToken().Type == token.EOF {
+				return
+			}
+		}
+		t.Fatalf("lexer did not reach EOF on %q", input)
+	})
+}
+
+func TestUnterminatedTemplate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"`abc", []token.Token{{Type: token.ILLEGAL, Literal: "`abc"}, {Type: token.EOF}}},
+		{"`a ${x} b", []token.Token{{Type: token.TEMPLATE_HEAD, Literal: "a "}, {Type: token.IDENT, Literal: "x"}, {Type: token.ILLEGAL, Literal: "} b"}, {Type: token.EOF}}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("%q: tokens[%d] - wrong token. Expected=%q %q, got=%q %q", tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
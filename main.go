@@ -2,12 +2,25 @@ package main
 
 import (
 	"fmt"
+	"monkey/compiler"
+	"monkey/module"
+	"monkey/object"
 	"monkey/repl"
+	"monkey/vm"
 	"os"
 	"os/user"
+	"path/filepath"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := runFile(os.Args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	usr, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -20,3 +33,27 @@ func main() {
 		panic(err)
 	}
 }
+
+// runFile compiles and runs the program at path. It can import the modules in its own directory and below, and the
+// os module can read the files there too.
+func runFile(path string) error {
+	root := os.DirFS(filepath.Dir(path))
+	loader := module.NewLoader(root)
+	name := filepath.Base(path)
+
+	program, err := loader.Load(name)
+	if err != nil {
+		return err
+	}
+
+	builtins := object.NewBuiltinRegistry()
+	builtins.RegisterModule(object.OSModule(root, nil))
+
+	comp := compiler.NewWithBuiltins(builtins)
+	comp.SetLoader(loader, name)
+	if err := comp.Compile(program); err != nil {
+		return err
+	}
+
+	return vm.New(comp.Bytecode()).Run()
+}
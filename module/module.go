@@ -0,0 +1,74 @@
+// Package module finds and parses the source files of the modules Monkey programs import.
+package module
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"path"
+	"strings"
+)
+
+// Loader reads modules from a file system. Import paths are slash-separated and resolved relative to the
+// directory of the importing file, and cannot reach outside the root of the file system.
+type Loader struct {
+	fsys     fs.FS
+	programs map[string]*ast.Program // parsed modules by path
+}
+
+// NewLoader returns a Loader reading modules from fsys, such as the os.DirFS of a program's directory.
+func NewLoader(fsys fs.FS) *Loader {
+	return &Loader{
+		fsys:     fsys,
+		programs: make(map[string]*ast.Program),
+	}
+}
+
+// Resolve returns the path of the module imported as spec by the file at from.
+// from is "" for a program that was not read from a file, whose imports are resolved from the root.
+func (l *Loader) Resolve(from, spec string) (string, error) {
+	p := path.Join(path.Dir(from), spec)
+	if !fs.ValidPath(p) || p == "." {
+		return "", fmt.Errorf("invalid module path: %s", spec)
+	}
+	return p, nil
+}
+
+// Load returns the program of the module at path, a path returned by Resolve.
+// The module is read and parsed the first time it is loaded only.
+func (l *Loader) Load(path string) (*ast.Program, error) {
+	if program, ok := l.programs[path]; ok {
+		return program, nil
+	}
+
+	source, err := fs.ReadFile(l.fsys, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("module not found: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read module %s: %w", path, err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser errors in module %s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	l.programs[path] = program
+	return program, nil
+}
+
+// CheckCycle returns an error when importing path while the modules in loading, outermost first, are still being
+// loaded would import a module into itself.
+func CheckCycle(loading []string, path string) error {
+	for i, p := range loading {
+		if p == path {
+			return fmt.Errorf("import cycle: %s -> %s", strings.Join(loading[i:], " -> "), path)
+		}
+	}
+	return nil
+}
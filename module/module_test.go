@@ -0,0 +1,80 @@
+package module_test
+
+import (
+	"monkey/module"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		from     string
+		spec     string
+		expected string
+		err      string
+	}{
+		{from: "", spec: "lib/math.mk", expected: "lib/math.mk"},
+		{from: "main.mk", spec: "./lib/math.mk", expected: "lib/math.mk"},
+		{from: "lib/strings.mk", spec: "math.mk", expected: "lib/math.mk"},
+		{from: "a/b/c.mk", spec: "../d.mk", expected: "a/d.mk"},
+		{from: "main.mk", spec: "../outside.mk", err: "invalid module path: ../outside.mk"},
+		{from: "main.mk", spec: "", err: "invalid module path: "},
+	}
+
+	loader := module.NewLoader(fstest.MapFS{})
+	for _, tt := range tests {
+		got, err := loader.Resolve(tt.from, tt.spec)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Resolve(%q, %q): wrong error. want=%q, got=%v", tt.from, tt.spec, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Resolve(%q, %q): unexpected error: %s", tt.from, tt.spec, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Resolve(%q, %q): want=%q, got=%q", tt.from, tt.spec, tt.expected, got)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ok.mk":     {Data: []byte("let x = 1;")},
+		"broken.mk": {Data: []byte("let x = ;")},
+	}
+	loader := module.NewLoader(fsys)
+
+	program, err := loader.Load("ok.mk")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if program.String() != "let x = 1;" {
+		t.Errorf("wrong program. got=%q", program.String())
+	}
+
+	again, _ := loader.Load("ok.mk")
+	if again != program {
+		t.Errorf("module was parsed again")
+	}
+
+	if _, err := loader.Load("missing.mk"); err == nil || err.Error() != "module not found: missing.mk" {
+		t.Errorf("wrong error for a missing module: %v", err)
+	}
+	if _, err := loader.Load("broken.mk"); err == nil || err.Error() != "parser errors in module broken.mk: no prefix parse function for ; found" {
+		t.Errorf("wrong error for a broken module: %v", err)
+	}
+}
+
+func TestCheckCycle(t *testing.T) {
+	if err := module.CheckCycle([]string{"a.mk", "b.mk"}, "c.mk"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	err := module.CheckCycle([]string{"main.mk", "a.mk", "b.mk"}, "a.mk")
+	if err == nil || err.Error() != "import cycle: a.mk -> b.mk -> a.mk" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
@@ -1,16 +1,29 @@
 package object
 
-import "fmt"
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
 
-// Builtins represents all the built-in functions in Monkey.
-var Builtins = []struct {
+// BuiltinDefinition pairs a built-in function with the name scripts use to refer to it.
+type BuiltinDefinition struct {
 	Name    string
 	Builtin *Builtin
-}{
+}
+
+// Builtins represents the default built-in functions in Monkey.
+// It is only read when populating a new BuiltinRegistry, so hosts should customise a registry rather than this slice.
+var Builtins = []BuiltinDefinition{
 	{
 		Name: "len",
 		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx CallContext, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments. got=%d, want=1", len(args))
 				}
@@ -20,7 +33,7 @@ var Builtins = []struct {
 					return &Integer{Value: int64(len(arg.Elements))}
 
 				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
+					return &Integer{Value: int64(arg.Len())}
 
 				default:
 					return newError("argument to `len` not supported, got %s", args[0].Type())
@@ -31,7 +44,7 @@ var Builtins = []struct {
 	{
 		Name: "first",
 		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx CallContext, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments. got=%d, want=1", len(args))
 				}
@@ -45,14 +58,14 @@ var Builtins = []struct {
 					return arr.Elements[0]
 				}
 
-				return nil
+				return NULL
 			},
 		},
 	},
 	{
 		Name: "last",
 		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx CallContext, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments. got=%d, want=1", len(args))
 				}
@@ -67,14 +80,14 @@ var Builtins = []struct {
 					return arr.Elements[length-1]
 				}
 
-				return nil
+				return NULL
 			},
 		},
 	},
 	{
 		Name: "tail",
 		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx CallContext, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments. got=%d, want=1", len(args))
 				}
@@ -89,14 +102,14 @@ var Builtins = []struct {
 					copy(newElems, arr.Elements[1:length])
 					return &Array{Elements: newElems}
 				}
-				return &Null{}
+				return NULL
 			},
 		},
 	},
 	{
 		Name: "push",
 		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx CallContext, args ...Object) Object {
 				if len(args) != 2 {
 					return newError("wrong number of arguments. got=%d, want=2", len(args))
 				}
@@ -118,19 +131,87 @@ var Builtins = []struct {
 	{
 		Name: "puts",
 		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx CallContext, args ...Object) Object {
+				for _, arg := range args {
+					fmt.Fprintln(ctx.Streams().Stdout, arg.Inspect())
+				}
+
+				return NULL
+			},
+		},
+	},
+	{
+		Name: "print",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				// unlike puts, the arguments are written one after another, without newlines.
+				for _, arg := range args {
+					fmt.Fprint(ctx.Streams().Stdout, arg.Inspect())
+				}
+
+				return NULL
+			},
+		},
+	},
+	{
+		Name: "eputs",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
 				for _, arg := range args {
-					fmt.Println(arg.Inspect())
+					fmt.Fprintln(ctx.Streams().Stderr, arg.Inspect())
+				}
+
+				return NULL
+			},
+		},
+	},
+	{
+		Name: "read_line",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+
+				// the line is returned without its line ending; null means the input has run out.
+				line, err := ctx.Streams().Stdin.ReadString('\n')
+				if err == io.EOF && line == "" {
+					return NULL
+				}
+				if err != nil && err != io.EOF {
+					return newError("cannot read line: %s", err)
 				}
 
-				return nil
+				line = strings.TrimSuffix(line, "\n")
+				return &String{Value: strings.TrimSuffix(line, "\r")}
+			},
+		},
+	},
+	{
+		Name: "format",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=0, want>=1")
+				}
+				format, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `format` must be STRING, got %s", args[0].Type())
+				}
+
+				str, err := formatObjects(format.Value, args[1:])
+				if err != nil {
+					return err
+				}
+
+				return &String{Value: str}
 			},
 		},
 	},
 	{
 		Name: "rest",
 		Builtin: &Builtin{
-			Fn: func(args ...Object) Object {
+			Fn: func(ctx CallContext, args ...Object) Object {
 				if len(args) != 1 {
 					return newError("wrong number of arguments. got=%d, want=1", len(args))
 				}
@@ -145,10 +226,707 @@ var Builtins = []struct {
 					return &Array{Elements: newElements}
 				}
 
-				return nil
+				return NULL
+			},
+		},
+	},
+	{
+		Name: "map",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `map` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				newElements := make([]Object, len(arr.Elements))
+				for i, e := range arr.Elements {
+					result, err := ctx.Call(args[1], e)
+					if err != nil {
+						return callbackError(err)
+					}
+					newElements[i] = result
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "filter",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `filter` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				newElements := make([]Object, 0, len(arr.Elements))
+				for _, e := range arr.Elements {
+					result, err := ctx.Call(args[1], e)
+					if err != nil {
+						return callbackError(err)
+					}
+					if isTruthy(result) {
+						newElements = append(newElements, e)
+					}
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "reduce",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `reduce` must be ARRAY, got %s", args[0].Type())
+				}
+
+				elements := args[0].(*Array).Elements
+
+				// without an initial value the first element seeds the accumulator.
+				var acc Object
+				if len(args) == 3 {
+					acc = args[2]
+				} else {
+					if len(elements) == 0 {
+						return newError("`reduce` of empty ARRAY with no initial value")
+					}
+					acc = elements[0]
+					elements = elements[1:]
+				}
+
+				for _, e := range elements {
+					result, err := ctx.Call(args[1], acc, e)
+					if err != nil {
+						return callbackError(err)
+					}
+					acc = result
+				}
+
+				return acc
+			},
+		},
+	},
+	{
+		Name: "each",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *Array:
+					for _, e := range arg.Elements {
+						if _, err := ctx.Call(args[1], e); err != nil {
+							return callbackError(err)
+						}
+					}
+
+				case *Hash:
+					// hashes are visited in insertion order, calling back with each key and value.
+					for _, pair := range arg.Pairs() {
+						if _, err := ctx.Call(args[1], pair.Key, pair.Value); err != nil {
+							return callbackError(err)
+						}
+					}
+
+				default:
+					return newError("argument to `each` must be ARRAY or HASH, got %s", args[0].Type())
+				}
+
+				return NULL
+			},
+		},
+	},
+	{
+		Name: "any",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `any` must be ARRAY, got %s", args[0].Type())
+				}
+
+				for _, e := range args[0].(*Array).Elements {
+					result, err := ctx.Call(args[1], e)
+					if err != nil {
+						return callbackError(err)
+					}
+					if isTruthy(result) {
+						return TRUE
+					}
+				}
+
+				return FALSE
 			},
 		},
 	},
+	{
+		Name: "all",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `all` must be ARRAY, got %s", args[0].Type())
+				}
+
+				for _, e := range args[0].(*Array).Elements {
+					result, err := ctx.Call(args[1], e)
+					if err != nil {
+						return callbackError(err)
+					}
+					if !isTruthy(result) {
+						return FALSE
+					}
+				}
+
+				return TRUE
+			},
+		},
+	},
+	{
+		Name: "find",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `find` must be ARRAY, got %s", args[0].Type())
+				}
+
+				for _, e := range args[0].(*Array).Elements {
+					result, err := ctx.Call(args[1], e)
+					if err != nil {
+						return callbackError(err)
+					}
+					if isTruthy(result) {
+						return e
+					}
+				}
+
+				return NULL
+			},
+		},
+	},
+	{
+		Name: "sort",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `sort` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				newElements := make([]Object, len(arr.Elements))
+				copy(newElements, arr.Elements)
+
+				if err := sortObjects(newElements, newElements); err != nil {
+					return callbackError(err)
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "sort_by",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `sort_by` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				newElements := make([]Object, len(arr.Elements))
+				copy(newElements, arr.Elements)
+
+				// compute every key once up front rather than calling back on each comparison.
+				keys := make([]Object, len(arr.Elements))
+				for i, e := range arr.Elements {
+					key, err := ctx.Call(args[1], e)
+					if err != nil {
+						return callbackError(err)
+					}
+					keys[i] = key
+				}
+
+				if err := sortObjects(keys, newElements); err != nil {
+					return callbackError(err)
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "zip",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) < 2 {
+					return newError("wrong number of arguments. got=%d, want>=2", len(args))
+				}
+
+				length := -1
+				for _, arg := range args {
+					if arg.Type() != ARRAY_OBJ {
+						return newError("argument to `zip` must be ARRAY, got %s", arg.Type())
+					}
+					if n := len(arg.(*Array).Elements); length == -1 || n < length {
+						length = n
+					}
+				}
+
+				newElements := make([]Object, length)
+				for i := range newElements {
+					tuple := make([]Object, len(args))
+					for j, arg := range args {
+						tuple[j] = arg.(*Array).Elements[i]
+					}
+					newElements[i] = &Array{Elements: tuple}
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "flatten",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `flatten` must be ARRAY, got %s", args[0].Type())
+				}
+
+				// only one level of nesting is removed, matching how `push` and `rest` treat nested arrays as values.
+				var newElements []Object
+				for _, e := range args[0].(*Array).Elements {
+					if inner, ok := e.(*Array); ok {
+						newElements = append(newElements, inner.Elements...)
+					} else {
+						newElements = append(newElements, e)
+					}
+				}
+
+				if newElements == nil {
+					newElements = []Object{}
+				}
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "range",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) < 1 || len(args) > 3 {
+					return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+				}
+
+				bounds := make([]int64, len(args))
+				for i, arg := range args {
+					integer, ok := arg.(*Integer)
+					if !ok {
+						return newError("argument to `range` must be INTEGER, got %s", arg.Type())
+					}
+					bounds[i] = integer.Value
+				}
+
+				// range(end), range(start, end) or range(start, end, step)
+				var start, end, step int64 = 0, bounds[0], 1
+				if len(bounds) > 1 {
+					start, end = bounds[0], bounds[1]
+				}
+				if len(bounds) > 2 {
+					step = bounds[2]
+				}
+				if step == 0 {
+					return newError("`range` step must not be zero")
+				}
+
+				newElements := []Object{}
+				for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+					newElements = append(newElements, &Integer{Value: i})
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "reverse",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `reverse` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				length := len(arr.Elements)
+				newElements := make([]Object, length)
+				for i, e := range arr.Elements {
+					newElements[length-1-i] = e
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "slice",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `slice` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				length := int64(len(arr.Elements))
+
+				bounds := []int64{0, length}
+				for i, arg := range args[1:] {
+					integer, ok := arg.(*Integer)
+					if !ok {
+						return newError("argument to `slice` must be INTEGER, got %s", arg.Type())
+					}
+					bounds[i] = clampIndex(integer.Value, length)
+				}
+
+				start, end := bounds[0], bounds[1]
+				if start > end {
+					start = end
+				}
+
+				newElements := make([]Object, end-start)
+				copy(newElements, arr.Elements[start:end])
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "index_of",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `index_of` must be ARRAY, got %s", args[0].Type())
+				}
+
+				for i, e := range args[0].(*Array).Elements {
+					if Equal(e, args[1]) {
+						return &Integer{Value: int64(i)}
+					}
+				}
+
+				return &Integer{Value: -1}
+			},
+		},
+	},
+	{
+		Name: "contains",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				switch arg := args[0].(type) {
+				case *Array:
+					for _, e := range arg.Elements {
+						if Equal(e, args[1]) {
+							return TRUE
+						}
+					}
+					return FALSE
+
+				case *String:
+					substr, ok := args[1].(*String)
+					if !ok {
+						return newError("argument to `contains` must be STRING, got %s", args[1].Type())
+					}
+					return NativeBoolToBooleanObject(strings.Contains(arg.Value, substr.Value))
+
+				default:
+					return newError("argument to `contains` must be ARRAY or STRING, got %s", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		Name: "keys",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+				}
+
+				pairs := args[0].(*Hash).Pairs()
+				newElements := make([]Object, len(pairs))
+				for i, pair := range pairs {
+					newElements[i] = pair.Key
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "values",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `values` must be HASH, got %s", args[0].Type())
+				}
+
+				pairs := args[0].(*Hash).Pairs()
+				newElements := make([]Object, len(pairs))
+				for i, pair := range pairs {
+					newElements[i] = pair.Value
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "entries",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `entries` must be HASH, got %s", args[0].Type())
+				}
+
+				pairs := args[0].(*Hash).Pairs()
+				newElements := make([]Object, len(pairs))
+				for i, pair := range pairs {
+					newElements[i] = &Array{Elements: []Object{pair.Key, pair.Value}}
+				}
+
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		Name: "has",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `has` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := AsHashable(args[1])
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				_, ok = args[0].(*Hash).Get(key)
+				return NativeBoolToBooleanObject(ok)
+			},
+		},
+	},
+	{
+		Name: "delete",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := AsHashable(args[1])
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				// like `push`, the original hash is left untouched and a copy without the key is returned.
+				newHash := args[0].(*Hash).Copy()
+				newHash.Delete(key)
+
+				return newHash
+			},
+		},
+	},
+	{
+		Name: "merge",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) < 2 {
+					return newError("wrong number of arguments. got=%d, want>=2", len(args))
+				}
+
+				// later hashes win when the same key appears more than once; the key keeps its first position.
+				newHash := NewHash()
+				for _, arg := range args {
+					hash, ok := arg.(*Hash)
+					if !ok {
+						return newError("argument to `merge` must be HASH, got %s", arg.Type())
+					}
+					for _, pair := range hash.Pairs() {
+						newHash.Set(pair.Key.(Hashable), pair.Value)
+					}
+				}
+
+				return newHash
+			},
+		},
+	},
+	{Name: "split", Builtin: splitBuiltin("split")},
+	{Name: "join", Builtin: joinBuiltin("join")},
+	{Name: "trim", Builtin: stringTransform("trim", strings.TrimSpace)},
+	{Name: "upper", Builtin: stringTransform("upper", strings.ToUpper)},
+	{Name: "lower", Builtin: stringTransform("lower", strings.ToLower)},
+	{Name: "replace", Builtin: replaceBuiltin("replace")},
+	{Name: "starts_with", Builtin: stringPredicate("starts_with", strings.HasPrefix)},
+	{Name: "ends_with", Builtin: stringPredicate("ends_with", strings.HasSuffix)},
+	{Name: "substr", Builtin: substrBuiltin("substr")},
+	{
+		Name: "type",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				return &String{Value: string(args[0].Type())}
+			},
+		},
+	},
+	{
+		Name: "str",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				if str, ok := args[0].(*String); ok {
+					return str
+				}
+
+				return &String{Value: args[0].Inspect()}
+			},
+		},
+	},
+	{
+		Name: "int",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer:
+					return arg
+
+				case *Boolean:
+					if arg.Value {
+						return &Integer{Value: 1}
+					}
+					return &Integer{Value: 0}
+
+				case *String:
+					return parseInteger(strings.TrimSpace(arg.Value), 10)
+
+				default:
+					return newError("cannot convert %s to INTEGER", args[0].Type())
+				}
+			},
+		},
+	},
+	{
+		Name: "bool",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+
+				return NativeBoolToBooleanObject(isTruthy(args[0]))
+			},
+		},
+	},
+	{
+		Name: "parse_int",
+		Builtin: &Builtin{
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				str, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `parse_int` must be STRING, got %s", args[0].Type())
+				}
+
+				base := int64(10)
+				if len(args) == 2 {
+					integer, ok := args[1].(*Integer)
+					if !ok {
+						return newError("argument to `parse_int` must be INTEGER, got %s", args[1].Type())
+					}
+					if integer.Value < 2 || integer.Value > 36 {
+						return newError("invalid base %d, must be between 2 and 36", integer.Value)
+					}
+					base = integer.Value
+				}
+
+				return parseInteger(str.Value, int(base))
+			},
+		},
+	},
+	{Name: "is_integer", Builtin: typePredicate(INTEGER_OBJ)},
+	{Name: "is_string", Builtin: typePredicate(STRING_OBJ)},
+	{Name: "is_boolean", Builtin: typePredicate(BOOLEAN_OBJ)},
+	{Name: "is_null", Builtin: typePredicate(NULL_OBJ)},
+	{Name: "is_array", Builtin: typePredicate(ARRAY_OBJ)},
+	{Name: "is_hash", Builtin: typePredicate(HASH_OBJ)},
+	{Name: "is_function", Builtin: typePredicate(FUNCTION_OBJ, BUILTIN_OBJ)},
 }
 
 // GetBuiltinByName allows us to fetch a built-in function by name.
@@ -165,3 +943,109 @@ func GetBuiltinByName(name string) *Builtin {
 func newError(format string, a ...any) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
+
+// callbackError turns an error from calling back into the script into the builtin's result.
+// Runtime errors are passed on as they are, so a thrown value and its stack survive the builtin.
+func callbackError(err error) *Error {
+	var errObj *Error
+	if errors.As(err, &errObj) {
+		return errObj
+	}
+	return newError("%s", err)
+}
+
+// isTruthy reports whether obj counts as true in a condition, using the same rules as both engines.
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
+
+// parseInteger parses s as an integer written in base, reporting an error result when it is not one.
+func parseInteger(s string, base int) Object {
+	value, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return newError("integer %q is out of range", s)
+		}
+		return newError("invalid integer %q in base %d", s, base)
+	}
+
+	return &Integer{Value: value}
+}
+
+// typePredicate returns a builtin that reports whether its argument has one of types.
+func typePredicate(types ...ObjectType) *Builtin {
+	return &Builtin{
+		Fn: func(ctx CallContext, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return NativeBoolToBooleanObject(slices.Contains(types, args[0].Type()))
+		},
+	}
+}
+
+// compareObjects orders two integers or two strings, returning a negative number when a sorts before b.
+func compareObjects(a, b Object) (int, error) {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return cmp.Compare(a.Value, b.Value), nil
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	}
+
+	return 0, fmt.Errorf("cannot compare %s and %s", a.Type(), b.Type())
+}
+
+// sortObjects stably sorts elements by the matching entry in keys, reordering keys alongside.
+// Passing the same slice for both sorts the elements by their own value.
+func sortObjects(keys, elements []Object) error {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+
+	var err error
+	sort.SliceStable(order, func(i, j int) bool {
+		c, cmpErr := compareObjects(keys[order[i]], keys[order[j]])
+		if cmpErr != nil && err == nil {
+			err = cmpErr
+		}
+		return c < 0
+	})
+	if err != nil {
+		return err
+	}
+
+	sortedKeys := make([]Object, len(keys))
+	sortedElements := make([]Object, len(elements))
+	for i, o := range order {
+		sortedKeys[i] = keys[o]
+		sortedElements[i] = elements[o]
+	}
+	copy(keys, sortedKeys)
+	copy(elements, sortedElements)
+
+	return nil
+}
+
+// clampIndex resolves a possibly negative index against length, counting negative indexes from the end,
+// and clamps the result to the range [0, length].
+func clampIndex(i, length int64) int64 {
+	if i < 0 {
+		i += length
+	}
+
+	return max(0, min(i, length))
+}
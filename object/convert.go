@@ -0,0 +1,505 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	objectType      = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+	callContextType = reflect.TypeOf((*CallContext)(nil)).Elem()
+)
+
+// FromGo converts a Go value into the equivalent Monkey object.
+//
+// Booleans, integers and strings map to their scalar objects, slices and arrays to *Array, and maps to *Hash.
+// Structs become a *Hash keyed by field name, or by the name given in a `monkey:"name"` tag; a tag of "-" skips the field.
+// Functions are wrapped as a *Builtin that checks and converts its arguments with ToGo before calling through.
+// A function whose first parameter is a CallContext receives the context of the engine calling it.
+// Pointers and interfaces are followed, nil becomes NULL and values that already implement Object are returned as-is.
+func FromGo(v any) (Object, error) {
+	if v == nil {
+		return NULL, nil
+	}
+
+	return fromGoValue(reflect.ValueOf(v), goRefs{})
+}
+
+// ToGo stores the Go equivalent of obj in the value target points to.
+//
+// It is the inverse of FromGo. When target points to an empty interface, integers become int64, arrays []any and
+// hashes map[string]any (or map[any]any when some keys are not strings), and objects without a Go equivalent are stored
+// unchanged. NULL stores the zero value.
+func ToGo(obj Object, target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+
+	return toGoValue(obj, rv.Elem())
+}
+
+func fromGoValue(v reflect.Value, refs goRefs) (Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NativeBoolToBooleanObject(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > 1<<63-1 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: value out of range", u)
+		}
+		return &Integer{Value: int64(u)}, nil
+
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if v.Kind() == reflect.Pointer {
+			if err := refs.enter(v); err != nil {
+				return nil, err
+			}
+			defer refs.leave(v)
+		}
+		return fromGoValue(v.Elem(), refs)
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return &Array{Elements: []Object{}}, nil
+			}
+			if err := refs.enter(v); err != nil {
+				return nil, err
+			}
+			defer refs.leave(v)
+		}
+
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := fromGoValue(v.Index(i), refs)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if err := refs.enter(v); err != nil {
+			return nil, err
+		}
+		defer refs.leave(v)
+		return fromGoMap(v, refs)
+
+	case reflect.Struct:
+		return fromGoStruct(v, refs)
+
+	case reflect.Func:
+		return fromGoFunc(v)
+
+	default:
+		return nil, fmt.Errorf("cannot convert Go value of type %s", v.Type())
+	}
+}
+
+func fromGoMap(v reflect.Value, refs goRefs) (Object, error) {
+	keys := v.MapKeys()
+	// Go randomises map iteration, sort the keys so the resulting hash is built in a stable order.
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	hash := NewHash()
+	for _, k := range keys {
+		key, err := fromGoValue(k, refs)
+		if err != nil {
+			return nil, err
+		}
+
+		hashKey, ok := AsHashable(key)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		value, err := fromGoValue(v.MapIndex(k), refs)
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func fromGoStruct(v reflect.Value, refs goRefs) (Object, error) {
+	hash := NewHash()
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+
+		value, err := fromGoValue(v.Field(i), refs)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+		}
+
+		hash.Set(&String{Value: name}, value)
+	}
+
+	return hash, nil
+}
+
+// goRefs holds the pointers, maps and slices on the way from the value FromGo was given to the one being converted,
+// so that a value which refers back to one of them is reported instead of followed forever. Values shared without a
+// cycle are converted each time they appear.
+type goRefs map[goRef]struct{}
+
+// goRef identifies what a pointer, map or slice refers to. The type and length tell apart a struct from its first
+// field, and a slice from the shorter slices of the same array.
+type goRef struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func newGoRef(v reflect.Value) goRef {
+	ref := goRef{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+	return ref
+}
+
+// enter records v on the way to the value being converted, or reports an error when v is on it already.
+func (refs goRefs) enter(v reflect.Value) error {
+	ref := newGoRef(v)
+	if _, ok := refs[ref]; ok {
+		return fmt.Errorf("cannot convert cyclic value of type %s", v.Type())
+	}
+	refs[ref] = struct{}{}
+	return nil
+}
+
+// leave removes v, once its conversion is complete.
+func (refs goRefs) leave(v reflect.Value) {
+	delete(refs, newGoRef(v))
+}
+
+// fieldName returns the hash key used for a struct field and whether the field is converted at all.
+func fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+
+	tag := field.Tag.Get("monkey")
+	if tag == "-" {
+		return "", false
+	}
+
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+
+	return field.Name, true
+}
+
+func fromGoFunc(v reflect.Value) (Object, error) {
+	if v.IsNil() {
+		return NULL, nil
+	}
+
+	t := v.Type()
+	numIn := t.NumIn()
+
+	// a leading CallContext parameter is filled in by the wrapper rather than by the script.
+	offset := 0
+	if numIn > 0 && t.In(0) == callContextType {
+		offset = 1
+		numIn--
+	}
+
+	fn := func(ctx CallContext, args ...Object) Object {
+		if t.IsVariadic() {
+			if len(args) < numIn-1 {
+				return newError("wrong number of arguments. got=%d, want>=%d", len(args), numIn-1)
+			}
+		} else if len(args) != numIn {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), numIn)
+		}
+
+		in := make([]reflect.Value, offset+len(args))
+		if offset == 1 {
+			in[0] = reflect.New(callContextType).Elem()
+			if ctx != nil {
+				in[0].Set(reflect.ValueOf(ctx))
+			}
+		}
+
+		for i, arg := range args {
+			paramType := t.In(offset + min(i, numIn-1))
+			if t.IsVariadic() && i >= numIn-1 {
+				paramType = paramType.Elem()
+			}
+
+			in[offset+i] = reflect.New(paramType).Elem()
+			if err := toGoValue(arg, in[offset+i]); err != nil {
+				return newError("argument %d: %s", i+1, err)
+			}
+		}
+
+		out := v.Call(in)
+
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return newError("%s", err)
+			}
+			out = out[:len(out)-1]
+		}
+
+		if len(out) == 0 {
+			return NULL
+		}
+
+		result, err := fromGoValue(out[0], goRefs{})
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
+	}
+
+	return &Builtin{Fn: fn}, nil
+}
+
+func toGoValue(obj Object, v reflect.Value) error {
+	isEmptyInterface := v.Kind() == reflect.Interface && v.NumMethod() == 0
+	if !isEmptyInterface && reflect.TypeOf(obj).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj.Type() == NULL_OBJ {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if !isEmptyInterface {
+			break
+		}
+
+		natural, err := naturalGoValue(obj)
+		if err != nil {
+			return err
+		}
+		if natural != nil {
+			v.Set(reflect.ValueOf(natural))
+		}
+		return nil
+
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			if v.OverflowInt(i.Value) {
+				return fmt.Errorf("cannot convert %d to %s: value out of range", i.Value, v.Type())
+			}
+			v.SetInt(i.Value)
+			return nil
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*Integer); ok {
+			if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+				return fmt.Errorf("cannot convert %d to %s: value out of range", i.Value, v.Type())
+			}
+			v.SetUint(uint64(i.Value))
+			return nil
+		}
+
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			v.SetString(s.Value)
+			return nil
+		}
+
+	case reflect.Pointer:
+		ptr := reflect.New(v.Type().Elem())
+		if err := toGoValue(obj, ptr.Elem()); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+
+	case reflect.Slice:
+		if arr, ok := obj.(*Array); ok {
+			slice := reflect.MakeSlice(v.Type(), len(arr.Elements), len(arr.Elements))
+			for i, element := range arr.Elements {
+				if err := toGoValue(element, slice.Index(i)); err != nil {
+					return fmt.Errorf("index %d: %w", i, err)
+				}
+			}
+			v.Set(slice)
+			return nil
+		}
+
+	case reflect.Array:
+		if arr, ok := obj.(*Array); ok {
+			if len(arr.Elements) != v.Len() {
+				return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), v.Type())
+			}
+			for i, element := range arr.Elements {
+				if err := toGoValue(element, v.Index(i)); err != nil {
+					return fmt.Errorf("index %d: %w", i, err)
+				}
+			}
+			return nil
+		}
+
+	case reflect.Map:
+		if hash, ok := obj.(*Hash); ok {
+			m := reflect.MakeMapWithSize(v.Type(), hash.Len())
+			for _, pair := range hash.Pairs() {
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := toGoValue(pair.Key, key); err != nil {
+					return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+				}
+				if !key.Comparable() {
+					return unusableKeyError(pair.Key)
+				}
+
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := toGoValue(pair.Value, value); err != nil {
+					return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+				}
+
+				m.SetMapIndex(key, value)
+			}
+			v.Set(m)
+			return nil
+		}
+
+	case reflect.Struct:
+		if hash, ok := obj.(*Hash); ok {
+			t := v.Type()
+			for i := 0; i < t.NumField(); i++ {
+				name, ok := fieldName(t.Field(i))
+				if !ok {
+					continue
+				}
+
+				value, ok := hash.Get(&String{Value: name})
+				if !ok {
+					continue
+				}
+
+				if err := toGoValue(value, v.Field(i)); err != nil {
+					return fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+				}
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), v.Type())
+}
+
+// naturalGoValue returns the Go value an object converts to when the target type is left open.
+// Objects without a Go equivalent, such as functions, are returned unchanged.
+func naturalGoValue(obj Object) (any, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+
+	case *Array:
+		elements := make([]any, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := naturalGoValue(element)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elements[i] = value
+		}
+		return elements, nil
+
+	case *Hash:
+		stringKeys := true
+		for _, pair := range obj.Pairs() {
+			if pair.Key.Type() != STRING_OBJ {
+				stringKeys = false
+				break
+			}
+		}
+
+		if stringKeys {
+			m := make(map[string]any, obj.Len())
+			for _, pair := range obj.Pairs() {
+				value, err := naturalGoValue(pair.Value)
+				if err != nil {
+					return nil, err
+				}
+				m[pair.Key.(*String).Value] = value
+			}
+			return m, nil
+		}
+
+		m := make(map[any]any, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key, err := naturalGoValue(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil && !reflect.ValueOf(key).Comparable() {
+				return nil, unusableKeyError(pair.Key)
+			}
+			value, err := naturalGoValue(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+
+	default:
+		return obj, nil
+	}
+}
+
+// unusableKeyError reports a hash key, such as an array or a hash, whose Go equivalent cannot be a Go map key.
+func unusableKeyError(key Object) error {
+	return fmt.Errorf("key %s: cannot convert %s to a Go map key", key.Inspect(), key.Type())
+}
@@ -0,0 +1,358 @@
+package object_test
+
+import (
+	"errors"
+	"monkey/object"
+	"reflect"
+	"testing"
+)
+
+type person struct {
+	Name    string `monkey:"name"`
+	Age     int    `monkey:"age"`
+	Tags    []string
+	Secret  string `monkey:"-"`
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{name: "nil converts to null", input: nil, expected: "null"},
+		{name: "int converts to integer", input: 42, expected: "42"},
+		{name: "uint8 converts to integer", input: uint8(7), expected: "7"},
+		{name: "bool converts to boolean", input: true, expected: "true"},
+		{name: "string converts to string", input: "monkey", expected: "monkey"},
+		{name: "slice converts to array", input: []int{1, 2, 3}, expected: "[1, 2, 3]"},
+		{name: "nested slices convert to nested arrays", input: [][]bool{{true}, {false}}, expected: "[[true], [false]]"},
+		{name: "nil pointer converts to null", input: (*int)(nil), expected: "null"},
+		{name: "objects are passed through", input: &object.Integer{Value: 5}, expected: "5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := object.FromGo(tt.input)
+			if err != nil {
+				t.Fatalf("FromGo returned error: %s", err)
+			}
+
+			if obj.Inspect() != tt.expected {
+				t.Errorf("wrong object. want=%q, got=%q", tt.expected, obj.Inspect())
+			}
+		})
+	}
+}
+
+func TestFromGoSharesBooleansAndNull(t *testing.T) {
+	obj, _ := object.FromGo(false)
+	if obj != object.FALSE {
+		t.Errorf("false did not convert to the shared FALSE instance")
+	}
+
+	obj, _ = object.FromGo(nil)
+	if obj != object.NULL {
+		t.Errorf("nil did not convert to the shared NULL instance")
+	}
+}
+
+func TestFromGoStruct(t *testing.T) {
+	obj, err := object.FromGo(person{Name: "Ivan", Age: 30, Tags: []string{"a"}, Secret: "x", private: 1})
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", obj, obj)
+	}
+
+	expected := map[string]string{"name": "Ivan", "age": "30", "Tags": "[a]"}
+	if hash.Len() != len(expected) {
+		t.Fatalf("hash has wrong number of pairs. want=%d, got=%d", len(expected), hash.Len())
+	}
+
+	for key, value := range expected {
+		got, ok := hash.Get(&object.String{Value: key})
+		if !ok {
+			t.Errorf("no pair for key %q", key)
+			continue
+		}
+		if got.Inspect() != value {
+			t.Errorf("wrong value for key %q. want=%q, got=%q", key, value, got.Inspect())
+		}
+	}
+
+	if hash.Inspect() != "{name: Ivan, age: 30, Tags: [a]}" {
+		t.Errorf("fields are not in declaration order. got=%q", hash.Inspect())
+	}
+}
+
+func TestFromGoMap(t *testing.T) {
+	obj, err := object.FromGo(map[string]int{"one": 1, "two": 2})
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	hash := obj.(*object.Hash)
+	value, ok := hash.Get(&object.String{Value: "two"})
+	if !ok || value.Inspect() != "2" {
+		t.Errorf("wrong value for key two: %+v", value)
+	}
+
+	obj, err = object.FromGo(map[[2]int]int{{1, 2}: 3})
+	if err != nil {
+		t.Fatalf("FromGo returned error for array keys: %s", err)
+	}
+	key := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}
+	if value, ok := obj.(*object.Hash).Get(key); !ok || value.Inspect() != "3" {
+		t.Errorf("wrong value for array key. got=%q", obj.Inspect())
+	}
+
+	if _, err := object.FromGo(map[chan int]int{make(chan int): 1}); err == nil {
+		t.Errorf("expected error for unsupported map key type")
+	}
+
+	if _, err := object.FromGo(1.5); err == nil {
+		t.Errorf("expected error for unsupported float value")
+	}
+}
+
+func TestFromGoCycles(t *testing.T) {
+	type node struct {
+		Value int
+		Next  *node
+	}
+	loop := &node{Value: 1}
+	loop.Next = loop
+
+	_, err := object.FromGo(loop)
+	if err == nil || err.Error() != "field Next: cannot convert cyclic value of type *object_test.node" {
+		t.Errorf("wrong error for a cyclic struct. got=%v", err)
+	}
+
+	items := []any{1}
+	items[0] = items
+	if _, err := object.FromGo(items); err == nil || err.Error() != "cannot convert cyclic value of type []interface {}" {
+		t.Errorf("wrong error for a cyclic slice. got=%v", err)
+	}
+
+	m := map[string]any{}
+	m["self"] = m
+	if _, err := object.FromGo(m); err == nil || err.Error() != "cannot convert cyclic value of type map[string]interface {}" {
+		t.Errorf("wrong error for a cyclic map. got=%v", err)
+	}
+
+	// a value shared without a cycle is converted wherever it appears.
+	shared := &node{Value: 2}
+	obj, err := object.FromGo([]*node{shared, shared})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if obj.Inspect() != "[{Value: 2, Next: null}, {Value: 2, Next: null}]" {
+		t.Errorf("wrong value. got=%s", obj.Inspect())
+	}
+}
+
+func TestFromGoFunc(t *testing.T) {
+	obj, err := object.FromGo(func(a int, b string) string {
+		return b + string(rune('0'+a))
+	})
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	builtin, ok := obj.(*object.Builtin)
+	if !ok {
+		t.Fatalf("object is not Builtin. got=%T (%+v)", obj, obj)
+	}
+
+	result := builtin.Fn(nil, &object.Integer{Value: 7}, &object.String{Value: "agent"})
+	if result.Inspect() != "agent7" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	tests := []struct {
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{
+			name:     "too few arguments",
+			args:     []object.Object{&object.Integer{Value: 1}},
+			expected: "wrong number of arguments. got=1, want=2",
+		},
+		{
+			name:     "argument of the wrong type",
+			args:     []object.Object{&object.String{Value: "1"}, &object.String{Value: "a"}},
+			expected: "argument 1: cannot convert STRING to int",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errObj, ok := builtin.Fn(nil, tt.args...).(*object.Error)
+			if !ok {
+				t.Fatalf("expected error object")
+			}
+			if errObj.Message != tt.expected {
+				t.Errorf("wrong error message. want=%q, got=%q", tt.expected, errObj.Message)
+			}
+		})
+	}
+}
+
+func TestFromGoFuncVariadicAndErrors(t *testing.T) {
+	obj, _ := object.FromGo(func(xs ...int) (int, error) {
+		if len(xs) == 0 {
+			return 0, errors.New("nothing to sum")
+		}
+
+		sum := 0
+		for _, x := range xs {
+			sum += x
+		}
+		return sum, nil
+	})
+	sum := obj.(*object.Builtin)
+
+	result := sum.Fn(nil, &object.Integer{Value: 1}, &object.Integer{Value: 2}, &object.Integer{Value: 3})
+	if result.Inspect() != "6" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	errObj, ok := sum.Fn(nil).(*object.Error)
+	if !ok || errObj.Message != "nothing to sum" {
+		t.Errorf("Go error was not returned as an error object. got=%+v", sum.Fn(nil))
+	}
+
+	obj, _ = object.FromGo(func() {})
+	if obj.(*object.Builtin).Fn(nil) != object.NULL {
+		t.Errorf("function without results did not return NULL")
+	}
+}
+
+type stubContext struct{}
+
+func (stubContext) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return fn.(*object.Builtin).Fn(stubContext{}, args...), nil
+}
+
+func (stubContext) Streams() *object.Streams {
+	return object.ProcessStreams()
+}
+
+func TestFromGoFuncWithCallContext(t *testing.T) {
+	obj, _ := object.FromGo(func(ctx object.CallContext, fn object.Object, x int) (object.Object, error) {
+		return ctx.Call(fn, &object.Integer{Value: int64(x)})
+	})
+	apply := obj.(*object.Builtin)
+
+	double, _ := object.FromGo(func(x int) int { return x * 2 })
+
+	result := apply.Fn(stubContext{}, double, &object.Integer{Value: 21})
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	errObj, ok := apply.Fn(stubContext{}, double).(*object.Error)
+	if !ok || errObj.Message != "wrong number of arguments. got=1, want=2" {
+		t.Errorf("CallContext parameter was counted as a script argument. got=%+v", errObj)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	array := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}
+
+	var ints []int
+	if err := object.ToGo(array, &ints); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if !reflect.DeepEqual(ints, []int{1, 2}) {
+		t.Errorf("wrong slice. got=%v", ints)
+	}
+
+	var small int8
+	if err := object.ToGo(&object.Integer{Value: 300}, &small); err == nil {
+		t.Errorf("expected overflow error converting 300 to int8")
+	}
+
+	var s string
+	if err := object.ToGo(&object.Integer{Value: 1}, &s); err == nil {
+		t.Errorf("expected error converting INTEGER to string")
+	}
+
+	if err := object.ToGo(array, ints); err == nil {
+		t.Errorf("expected error for non-pointer target")
+	}
+
+	var obj object.Object
+	if err := object.ToGo(array, &obj); err != nil || obj != array {
+		t.Errorf("object target did not receive the object unchanged")
+	}
+}
+
+func TestToGoRoundTrip(t *testing.T) {
+	in := person{Name: "Ivan", Age: 30, Tags: []string{"a", "b"}}
+
+	obj, err := object.FromGo(in)
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	var out person
+	if err := object.ToGo(obj, &out); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip changed value. want=%+v, got=%+v", in, out)
+	}
+
+	var m map[string]int
+	obj, _ = object.FromGo(map[string]int{"a": 1})
+	if err := object.ToGo(obj, &m); err != nil || m["a"] != 1 {
+		t.Errorf("map round trip failed. got=%v (%v)", m, err)
+	}
+}
+
+func TestToGoCompositeKeys(t *testing.T) {
+	hash := object.NewHash()
+	hash.Set(&object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}, &object.Integer{Value: 2})
+
+	var natural any
+	err := object.ToGo(hash, &natural)
+	if err == nil || err.Error() != "key [1]: cannot convert ARRAY to a Go map key" {
+		t.Errorf("wrong error converting to any. got=%v", err)
+	}
+
+	var m map[any]int
+	err = object.ToGo(hash, &m)
+	if err == nil || err.Error() != "key [1]: cannot convert ARRAY to a Go map key" {
+		t.Errorf("wrong error converting to map[any]int. got=%v", err)
+	}
+
+	// keys that convert to comparable Go values still work.
+	hash = object.NewHash()
+	hash.Set(&object.Integer{Value: 1}, &object.Integer{Value: 2})
+	if err := object.ToGo(hash, &natural); err != nil || !reflect.DeepEqual(natural, map[any]any{int64(1): int64(2)}) {
+		t.Errorf("wrong value. got=%#v (%v)", natural, err)
+	}
+}
+
+func TestToGoEmptyInterface(t *testing.T) {
+	obj, _ := object.FromGo(map[string]any{
+		"list": []any{1, "two", true, nil},
+	})
+
+	var out any
+	if err := object.ToGo(obj, &out); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+
+	expected := map[string]any{"list": []any{int64(1), "two", true, nil}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("wrong value. want=%#v, got=%#v", expected, out)
+	}
+}
@@ -2,22 +2,57 @@ package object
 
 // Environment represents a storage for objects, maintaining a mapping between variable names and their corresponding objects.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store     map[string]Object
+	outer     *Environment
+	builtins  *BuiltinRegistry // only set on the outermost Environment
+	importer  Importer         // only set on the outermost Environment
+	callDepth int              // number of function calls active while this Environment is in use
+	scoped    bool             // Set passes names missing from store on to outer
+}
+
+// Importer loads the modules imported by the code running in an Environment.
+type Importer interface {
+	// Import returns the *Module imported as path from env, or an *Error if it cannot be loaded.
+	Import(env *Environment, path string) Object
 }
 
 // NewEnclosedEnvironment creates a new Environment containing a reference to an outer Environment for nested scopes.
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+	return &Environment{
+		store: make(map[string]Object),
+		outer: outer,
+	}
+}
+
+// NewCallEnvironment creates the Environment for a function call, enclosed by the function's outer Environment and
+// one call deeper than the caller's Environment.
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.callDepth = caller.callDepth + 1
 	return env
 }
 
-// NewEnvironment creates and returns a new Environment with an empty store.
+// NewScopedEnvironment creates an Environment enclosed by outer that binds name to val only for the code running
+// in it, such as the parameter of a catch block. Other names it sets go to outer.
+func NewScopedEnvironment(outer *Environment, name string, val Object) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.store[name] = val
+	env.callDepth = outer.callDepth
+	env.scoped = true
+	return env
+}
+
+// NewEnvironment creates and returns a new Environment with an empty store and the default builtins.
 func NewEnvironment() *Environment {
+	return NewEnvironmentWithBuiltins(NewBuiltinRegistry())
+}
+
+// NewEnvironmentWithBuiltins creates and returns a new Environment with an empty store that resolves builtins from the given registry.
+func NewEnvironmentWithBuiltins(builtins *BuiltinRegistry) *Environment {
 	s := make(map[string]Object)
 	return &Environment{
-		store: s,
+		store:    s,
+		builtins: builtins,
 	}
 }
 
@@ -32,6 +67,36 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set assigns the given Object to the specified name in the Environment's store and returns the Object.
 func (e *Environment) Set(name string, val Object) Object {
+	if _, ok := e.store[name]; !ok && e.scoped {
+		return e.outer.Set(name, val)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Builtins returns the builtin registry of the outermost Environment.
+func (e *Environment) Builtins() *BuiltinRegistry {
+	if e.builtins == nil && e.outer != nil {
+		return e.outer.Builtins()
+	}
+	return e.builtins
+}
+
+// CallDepth returns the number of function calls active while this Environment is in use.
+func (e *Environment) CallDepth() int {
+	return e.callDepth
+}
+
+// SetImporter sets the Importer used to load the modules imported by code running in the Environment and the
+// Environments it encloses. It must be called on an outermost Environment.
+func (e *Environment) SetImporter(importer Importer) {
+	e.importer = importer
+}
+
+// Importer returns the Importer of the outermost Environment, or nil when imports are not available.
+func (e *Environment) Importer() Importer {
+	if e.importer == nil && e.outer != nil {
+		return e.outer.Importer()
+	}
+	return e.importer
+}
@@ -0,0 +1,68 @@
+package object
+
+// Equal reports whether two objects hold the same value.
+//
+// Integers, booleans and strings compare by value, any two nulls are equal, and arrays and hashes compare their
+// contents recursively; hash pairs may appear in any order. Every other object is only equal to itself.
+// Arrays and hashes that contain themselves are handled by treating a comparison already in progress as equal.
+func Equal(a, b Object) bool {
+	return equal(a, b, make(map[[2]Object]bool))
+}
+
+func equal(a, b Object, visiting map[[2]Object]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if a == b || visiting[[2]Object{a, b}] {
+			return true
+		}
+		visiting[[2]Object{a, b}] = true
+
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], visiting) {
+				return false
+			}
+		}
+		return true
+
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if a == b || visiting[[2]Object{a, b}] {
+			return true
+		}
+		visiting[[2]Object{a, b}] = true
+
+		for _, pair := range a.pairs {
+			value, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !equal(pair.Value, value, visiting) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return a == b
+	}
+}
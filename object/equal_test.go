@@ -0,0 +1,69 @@
+package object_test
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	one := &object.Integer{Value: 1}
+	fn := &object.Function{}
+
+	hashA := object.NewHash()
+	hashA.Set(&object.String{Value: "a"}, one)
+	hashA.Set(&object.String{Value: "b"}, &object.Array{Elements: []object.Object{one}})
+	hashB := object.NewHash()
+	hashB.Set(&object.String{Value: "b"}, &object.Array{Elements: []object.Object{one}})
+	hashB.Set(&object.String{Value: "a"}, &object.Integer{Value: 1})
+
+	tests := []struct {
+		name     string
+		a, b     object.Object
+		expected bool
+	}{
+		{"equal integers", one, &object.Integer{Value: 1}, true},
+		{"equal strings", &object.String{Value: "x"}, &object.String{Value: "x"}, true},
+		{"different types", one, &object.String{Value: "1"}, false},
+		{"nulls", object.NULL, &object.Null{}, true},
+		{"equal arrays", &object.Array{Elements: []object.Object{one, object.TRUE}}, &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, object.TRUE}}, true},
+		{"arrays of different lengths", &object.Array{Elements: []object.Object{one}}, &object.Array{}, false},
+		{"hashes with pairs in a different order", hashA, hashB, true},
+		{"hash and array", hashA, &object.Array{}, false},
+		{"same function", fn, fn, true},
+		{"different functions", fn, &object.Function{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := object.Equal(tt.a, tt.b); got != tt.expected {
+				t.Errorf("wrong result. want=%t, got=%t", tt.expected, got)
+			}
+			if got := object.Equal(tt.b, tt.a); got != tt.expected {
+				t.Errorf("wrong result with arguments swapped. want=%t, got=%t", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEqualCycles(t *testing.T) {
+	a := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, nil}}
+	a.Elements[1] = a
+	b := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, nil}}
+	b.Elements[1] = b
+
+	if !object.Equal(a, b) {
+		t.Errorf("arrays with the same cyclic structure are not equal")
+	}
+
+	c := &object.Array{Elements: []object.Object{&object.Integer{Value: 2}, nil}}
+	c.Elements[1] = c
+	if object.Equal(a, c) {
+		t.Errorf("cyclic arrays with different elements are equal")
+	}
+
+	h := object.NewHash()
+	h.Set(&object.String{Value: "self"}, h)
+	if !object.Equal(h, h.Copy()) {
+		t.Errorf("self-referencing hash is not equal to its copy")
+	}
+}
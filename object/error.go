@@ -0,0 +1,81 @@
+package object
+
+import "fmt"
+
+// AnonymousFunction is the name recorded in an error's stack for a function that was not bound with let.
+const AnonymousFunction = "<anonymous>"
+
+// NewArityError returns the error for a call passing got arguments to a function that takes between min and max,
+// or at least min when it is variadic.
+func NewArityError(min, max int, variadic bool, got int) *Error {
+	var want string
+	switch {
+	case variadic:
+		want = fmt.Sprintf("%d+", min)
+	case min == max:
+		want = fmt.Sprintf("%d", min)
+	default:
+		want = fmt.Sprintf("%d..%d", min, max)
+	}
+	return &Error{Message: fmt.Sprintf("wrong number of arguments: want=%s, got=%d", want, got)}
+}
+
+// NewThrownError returns the error raised by `throw value`.
+//
+// A string becomes the message as is. A hash with a string "message", such as a caught error, is thrown again
+// with its message, stack and value, so rethrowing a caught error keeps where it came from. Anything else uses
+// its Inspect output as the message.
+func NewThrownError(value Object) *Error {
+	switch value := value.(type) {
+	case *String:
+		return &Error{Message: value.Value, Value: value}
+
+	case *Hash:
+		message, ok := value.Get(&String{Value: "message"})
+		if msg, isString := message.(*String); ok && isString {
+			err := &Error{Message: msg.Value, Value: value}
+			if stack, ok := value.Get(&String{Value: "stack"}); ok {
+				if frames, ok := stack.(*Array); ok {
+					for _, frame := range frames.Elements {
+						err.Stack = append(err.Stack, frame.Inspect())
+					}
+				}
+			}
+			if thrown, ok := value.Get(&String{Value: "value"}); ok {
+				err.Value = thrown
+			}
+			return err
+		}
+	}
+
+	return &Error{Message: value.Inspect(), Value: value}
+}
+
+// Unwind records that the error passed out of the function called name, returning the error for convenience.
+func (e *Error) Unwind(name string) *Error {
+	if name == "" {
+		name = AnonymousFunction
+	}
+	e.Stack = append(e.Stack, name)
+	return e
+}
+
+// ToHash returns the value a catch clause binds for the error: a hash with the message, the stack as an array
+// of function names and the thrown value, which is null for errors raised by the runtime.
+func (e *Error) ToHash() *Hash {
+	frames := make([]Object, len(e.Stack))
+	for i, name := range e.Stack {
+		frames[i] = &String{Value: name}
+	}
+
+	value := e.Value
+	if value == nil {
+		value = NULL
+	}
+
+	hash := NewHash()
+	hash.Set(&String{Value: "message"}, &String{Value: e.Message})
+	hash.Set(&String{Value: "stack"}, &Array{Elements: frames})
+	hash.Set(&String{Value: "value"}, value)
+	return hash
+}
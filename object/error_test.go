@@ -0,0 +1,49 @@
+package object_test
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+func TestThrownErrors(t *testing.T) {
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "message"}, &object.String{Value: "custom"})
+
+	tests := []struct {
+		value    object.Object
+		expected string
+	}{
+		{&object.String{Value: "boom"}, "boom"},
+		{&object.Integer{Value: 5}, "5"},
+		{&object.Array{Elements: []object.Object{object.TRUE}}, "[true]"},
+		{hash, "custom"},
+	}
+
+	for _, tt := range tests {
+		err := object.NewThrownError(tt.value)
+		if err.Message != tt.expected {
+			t.Errorf("wrong message for %s. want=%q, got=%q", tt.value.Inspect(), tt.expected, err.Message)
+		}
+		if err.Value != tt.value {
+			t.Errorf("thrown value not kept for %s. got=%v", tt.value.Inspect(), err.Value)
+		}
+	}
+}
+
+func TestRethrownErrorsKeepTheirStack(t *testing.T) {
+	original := &object.Error{Message: "division by zero"}
+	original.Unwind("inner").Unwind("")
+
+	caught := original.ToHash()
+	if caught.Inspect() != "{message: division by zero, stack: [inner, <anonymous>], value: null}" {
+		t.Fatalf("wrong caught error. got=%s", caught.Inspect())
+	}
+
+	rethrown := object.NewThrownError(caught)
+	if rethrown.Message != original.Message {
+		t.Errorf("wrong message. want=%q, got=%q", original.Message, rethrown.Message)
+	}
+	if rethrown.ToHash().Inspect() != caught.Inspect() {
+		t.Errorf("rethrown error changed. want=%s, got=%s", caught.Inspect(), rethrown.ToHash().Inspect())
+	}
+}
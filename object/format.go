@@ -0,0 +1,106 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatObjects substitutes args into the verbs of format, like fmt.Sprintf for Monkey objects.
+//
+// A verb is written %[flags][width][.precision]verb, with the flags "-+# 0" of package fmt:
+//
+//	%s  the Inspect output of any object, so strings are written without quotes
+//	%v  the same as %s
+//	%q  a string in double quotes
+//	%d  an integer in decimal
+//	%x  an integer or string in hexadecimal
+//	%f  an integer written with a decimal point, e.g. %.2f
+//	%t  a boolean
+//	%%  a literal percent sign
+//
+// Widths and precisions count characters, so columns line up for text that is not ASCII.
+func formatObjects(format string, args []Object) (string, *Error) {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("-+# 0123456789.", format[i]) >= 0 {
+			i++
+		}
+		if i == len(format) {
+			return "", newError("format string ends in the middle of the verb %s", format[start:])
+		}
+
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return "", newError("missing argument for the verb %s", format[start:i+1])
+		}
+
+		value, err := formatValue(verb, args[next])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&out, format[start:i+1], value)
+		next++
+	}
+
+	if next < len(args) {
+		return "", newError("wrong number of arguments for the format string. got=%d, want=%d", len(args), next)
+	}
+
+	return out.String(), nil
+}
+
+// formatValue returns the Go value fmt formats for arg under verb.
+func formatValue(verb rune, arg Object) (any, *Error) {
+	switch verb {
+	case 's', 'v':
+		return arg.Inspect(), nil
+
+	case 'q':
+		if str, ok := arg.(*String); ok {
+			return str.Value, nil
+		}
+
+	case 'd':
+		if integer, ok := arg.(*Integer); ok {
+			return integer.Value, nil
+		}
+
+	case 'x', 'X':
+		switch arg := arg.(type) {
+		case *Integer:
+			return arg.Value, nil
+		case *String:
+			return arg.Value, nil
+		}
+
+	case 'f':
+		if integer, ok := arg.(*Integer); ok {
+			return float64(integer.Value), nil
+		}
+
+	case 't':
+		if boolean, ok := arg.(*Boolean); ok {
+			return boolean.Value, nil
+		}
+
+	default:
+		return nil, newError("unknown format verb %%%c", verb)
+	}
+
+	return nil, newError("format verb %%%c does not accept %s", verb, arg.Type())
+}
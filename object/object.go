@@ -2,7 +2,9 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash"
 	"hash/fnv"
 	"monkey/ast"
 	"monkey/code"
@@ -24,21 +26,75 @@ const (
 	ARRAY_OBJ             = "ARRAY"
 	HASH_OBJ              = "HASH"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
-	CLOSURE_OBJ           = "CLOUSRE"
+	MODULE_OBJ            = "MODULE"
+	COMPILED_MODULE_OBJ   = "COMPILED_MODULE_OBJ"
+)
+
+var (
+	// TRUE is the shared instance of boolean true. Both engines compare booleans by identity, so every true value must be this one.
+	TRUE = &Boolean{Value: true}
+
+	// FALSE is the shared instance of boolean false.
+	FALSE = &Boolean{Value: false}
+
+	// NULL is the shared instance of null.
+	NULL = &Null{}
 )
 
 // BuiltinFunction represents a function type that accepts a variable number of Object arguments and returns an Object.
-type BuiltinFunction func(args ...Object) Object
+// ctx is the engine running the builtin and can be used to call back into script functions.
+type BuiltinFunction func(ctx CallContext, args ...Object) Object
+
+// CallContext is handed to every builtin call and gives it access to the engine that invoked it.
+type CallContext interface {
+	// Call synchronously invokes fn with args and returns its result.
+	// fn may be a *Closure when running in the VM, a *Function when running in the evaluator, or a *Builtin in either.
+	// Runtime errors raised while running fn, including *Error results, are returned as a Go error.
+	Call(fn Object, args ...Object) (Object, error)
+
+	// Streams returns the standard streams of the interpreter instance running the builtin.
+	Streams() *Streams
+}
 
 type Object interface {
 	Type() ObjectType
 	Inspect() string
 }
 
+// Hashable is implemented by objects that can be used as hash keys.
+// Arrays and hashes implement it too, but are only usable as keys when everything they contain is; use AsHashable to check.
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
+// AsHashable returns obj as a Hashable if it can be used as a hash key.
+func AsHashable(obj Object) (Hashable, bool) {
+	switch obj := obj.(type) {
+	case *Integer, *Boolean, *String:
+		return obj.(Hashable), true
+
+	case *Array:
+		for _, e := range obj.Elements {
+			if _, ok := AsHashable(e); !ok {
+				return nil, false
+			}
+		}
+		return obj, true
+
+	case *Hash:
+		for _, pair := range obj.pairs {
+			if _, ok := AsHashable(pair.Value); !ok {
+				return nil, false
+			}
+		}
+		return obj, true
+
+	default:
+		return nil, false
+	}
+}
+
 // Integer represents an integer object with a 64-bit Value field.
 type Integer struct {
 	Value int64
@@ -62,8 +118,11 @@ type ReturnValue struct {
 // Function represents a function as an object.
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // the default value of each parameter, nil for those without one; empty when there are none
+	Rest       *ast.Identifier  // the ...rest parameter collecting any remaining arguments, if there is one
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string // the let binding the function literal was assigned to, if any
 }
 
 // Builtin represents a structure that holds a BuiltinFunction which defines the behavior of the built-in functionality.
@@ -89,30 +148,73 @@ type HashPair struct {
 }
 
 // Hash is a structure representing a collection of key-value pairs, where keys are defined by their unique HashKey.
+// Pairs are kept in insertion order so that inspecting or iterating a hash always gives the same result.
+// Keys whose HashKeys collide share a bucket and are told apart by comparing the keys themselves.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair
+	buckets map[HashKey][]int // indexes into pairs
+	hasher  Hasher
 }
 
+// Hasher computes the HashKey used to place a key in a Hash.
+type Hasher func(key Hashable) HashKey
+
 // Error represents an error object in the system with a message.
 type Error struct {
 	Message string
+	Stack   []string // names of the functions the error unwound through, innermost first
+	Value   Object   // the value given to throw, nil for errors raised by the runtime
 }
 
 // Null represents the absence of a value.
 type Null struct {
 }
 
-// CompiledFunction holds the compiled bytecode of a function along with the number of local bindings and parameters it expects
+// CompiledFunction holds the compiled bytecode of a function along with the number of local bindings and parameters it expects.
+// A call has to pass between MinParameters and NumParameters arguments, or more when the function is Variadic, in
+// which case the extra arguments are packed into an array in the local slot following the parameters.
 type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	MinParameters int    // the number of parameters without a default value
+	Variadic      bool   // whether the function has a ...rest parameter
+	Name          string // the let binding the function literal was assigned to, if any
 }
 
 // Closure wraps a CompiledFunction, along with its captured free variables.
 type Closure struct {
-	Fn   *CompiledFunction
-	Free []Object
+	Fn      *CompiledFunction
+	Free    []Object
+	Globals []Object // the global bindings of the module the closure was created in
+}
+
+// Module is the value of an import: the bindings exported by a module, which can be indexed like a hash.
+type Module struct {
+	Name    string // the path the module was loaded from
+	Exports *Hash  // the exported bindings by name, in the order the module binds them
+}
+
+// CompiledModule holds the compiled bytecode of an imported module, which runs once with its own global bindings.
+type CompiledModule struct {
+	Name         string
+	Instructions code.Instructions
+	NumGlobals   int
+	Exports      []ModuleExport
+}
+
+// ModuleExport is a binding exported by a CompiledModule, stored at Index in the module's global bindings.
+type ModuleExport struct {
+	Name  string
+	Index int
+}
+
+// NativeBoolToBooleanObject returns the shared Boolean instance matching input.
+func NativeBoolToBooleanObject(input bool) *Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
 }
 
 // Inspect returns a string representation of the Integer's value.
@@ -170,6 +272,35 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// HashKey combines the hash keys of the elements in order, so arrays with equal elements share a HashKey.
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	for _, e := range a.Elements {
+		writeHashKey(h, e)
+	}
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
+}
+
+// HashKey combines the hash keys of the pairs without regard to their order, so hashes with equal pairs share a HashKey.
+func (h *Hash) HashKey() HashKey {
+	var value uint64
+	for _, pair := range h.pairs {
+		ph := fnv.New64a()
+		writeHashKey(ph, pair.Key)
+		writeHashKey(ph, pair.Value)
+		value += ph.Sum64()
+	}
+	return HashKey{Type: h.Type(), Value: value}
+}
+
+// writeHashKey feeds the HashKey of obj into h. Objects that cannot be hashed only contribute their type.
+func writeHashKey(h hash.Hash64, obj Object) {
+	h.Write([]byte(obj.Type()))
+	if key, ok := AsHashable(obj); ok {
+		binary.Write(h, binary.LittleEndian, key.HashKey().Value)
+	}
+}
+
 // Inspect returns the string representation of the returned value by invoking the Inspect method on the wrapped Object.
 func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
@@ -190,6 +321,11 @@ func (e *Error) Type() ObjectType {
 	return ERROR_OBJ
 }
 
+// Error returns the message, so that an *Error can also be returned as a Go error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
 // Inspect returns a string representation of a Null object.
 func (n *Null) Inspect() string {
 	return "null"
@@ -203,15 +339,10 @@ func (n *Null) Type() ObjectType {
 // Inspect returns a string representation of a Function object.
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
-	var params []string
-
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
 
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.FormatParameters(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -256,12 +387,109 @@ func (a *Array) Type() ObjectType {
 	return ARRAY_OBJ
 }
 
+// NewHash returns an empty Hash.
+func NewHash() *Hash {
+	return NewHashWithHasher(nil)
+}
+
+// NewHashWithHasher returns an empty Hash that places keys using hasher instead of their HashKey method.
+func NewHashWithHasher(hasher Hasher) *Hash {
+	return &Hash{
+		buckets: make(map[HashKey][]int),
+		hasher:  hasher,
+	}
+}
+
+// Set stores value under key. A key that is already present keeps its original position.
+func (h *Hash) Set(key Hashable, value Object) {
+	if i, ok := h.index(key); ok {
+		h.pairs[i].Value = value
+		return
+	}
+
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+
+	hashKey := h.hashKey(key)
+	h.buckets[hashKey] = append(h.buckets[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+// Get returns the value stored under key.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	i, ok := h.index(key)
+	if !ok {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+// Delete removes key from the hash, if present.
+func (h *Hash) Delete(key Hashable) {
+	i, ok := h.index(key)
+	if !ok {
+		return
+	}
+
+	h.pairs = append(h.pairs[:i:i], h.pairs[i+1:]...)
+
+	// every pair after the removed one has moved down a place, so the buckets are rebuilt from scratch.
+	h.buckets = make(map[HashKey][]int, len(h.pairs))
+	for j, pair := range h.pairs {
+		hashKey := h.hashKey(pair.Key.(Hashable))
+		h.buckets[hashKey] = append(h.buckets[hashKey], j)
+	}
+}
+
+// Len returns the number of pairs in the hash.
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns the pairs of the hash in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.pairs))
+	copy(pairs, h.pairs)
+	return pairs
+}
+
+// Copy returns a shallow copy of the hash that can be modified without affecting the original.
+func (h *Hash) Copy() *Hash {
+	c := &Hash{
+		pairs:   h.Pairs(),
+		buckets: make(map[HashKey][]int, len(h.buckets)),
+		hasher:  h.hasher,
+	}
+	for k, bucket := range h.buckets {
+		c.buckets[k] = append([]int(nil), bucket...)
+	}
+	return c
+}
+
+// index returns the position of key in pairs, searching the bucket for its HashKey.
+func (h *Hash) index(key Hashable) (int, bool) {
+	for _, i := range h.buckets[h.hashKey(key)] {
+		if Equal(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (h *Hash) hashKey(key Hashable) HashKey {
+	if h.hasher != nil {
+		return h.hasher(key)
+	}
+	return key.HashKey()
+}
+
 // Inspect returns a string representation of a Hash object.
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	var pairs []string
 
-	for _, p := range h.Pairs {
+	for _, p := range h.Pairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", p.Key.Inspect(), p.Value.Inspect()))
 	}
 
@@ -287,12 +515,43 @@ func (cf *CompiledFunction) Inspect() string {
 	return fmt.Sprintf("CompiledFunction[%p]", cf)
 }
 
-// Type returns the CLOSURE_OBJ object type.
+// Type returns the FUNCTION_OBJ object type. Closures are how the VM represents functions, so scripts and error
+// messages see the same type from both engines.
 func (c *Closure) Type() ObjectType {
-	return CLOSURE_OBJ
+	return FUNCTION_OBJ
 }
 
 // Inspect returns a string representation of a Closure object, including its memory address.
 func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
+
+// Type returns the MODULE_OBJ object type.
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+
+// Inspect returns a string representation of a Module object, naming the path it was loaded from.
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("<module %s>", m.Name)
+}
+
+// Get returns the value the module exports under key. Indexing a module with anything it does not export is an error.
+func (m *Module) Get(key Object) (Object, *Error) {
+	if name, ok := key.(*String); ok {
+		if value, ok := m.Exports.Get(name); ok {
+			return value, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("module %s has no export %s", m.Name, key.Inspect())}
+}
+
+// Type returns the COMPILED_MODULE_OBJ object type.
+func (cm *CompiledModule) Type() ObjectType {
+	return COMPILED_MODULE_OBJ
+}
+
+// Inspect returns a string representation of a CompiledModule object, naming the path it was loaded from.
+func (cm *CompiledModule) Inspect() string {
+	return fmt.Sprintf("CompiledModule[%s]", cm.Name)
+}
@@ -1,6 +1,7 @@
 package object_test
 
 import (
+	"fmt"
 	"monkey/object"
 	"testing"
 )
@@ -24,3 +25,207 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash key")
 	}
 }
+
+func TestBuiltinRegistry(t *testing.T) {
+	registry := object.NewBuiltinRegistry()
+
+	lenIndex := -1
+	for i, def := range registry.Definitions() {
+		if def.Name == "len" {
+			lenIndex = i
+		}
+	}
+	if lenIndex == -1 {
+		t.Fatalf("default registry is missing len")
+	}
+
+	double := func(_ object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}
+
+	registry.Register("double", double)
+	if _, ok := registry.Lookup("double"); !ok {
+		t.Errorf("registered builtin double not found")
+	}
+
+	registry.Register("len", double)
+	if registry.Get(lenIndex) == nil || registry.Get(lenIndex).Fn(nil, &object.Integer{Value: 2}).(*object.Integer).Value != 4 {
+		t.Errorf("replacing len did not keep its index")
+	}
+
+	registry.Remove("len")
+	if _, ok := registry.Lookup("len"); ok {
+		t.Errorf("removed builtin len is still visible")
+	}
+	if registry.Get(lenIndex) != nil {
+		t.Errorf("removed builtin len is still stored at index %d", lenIndex)
+	}
+
+	if _, ok := object.NewBuiltinRegistry().Lookup("len"); !ok {
+		t.Errorf("changes to one registry leaked into a new registry")
+	}
+}
+
+func TestBuiltinRegistryLimit(t *testing.T) {
+	registry := object.NewBuiltinRegistry()
+	noop := func(_ object.CallContext, _ ...object.Object) object.Object { return object.NULL }
+	for i := len(registry.Definitions()); i < object.MaxBuiltins; i++ {
+		registry.Register(fmt.Sprintf("b%d", i), noop)
+	}
+
+	// replacing a builtin takes no new index, so it still works on a full registry.
+	registry.Register("len", noop)
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering past MaxBuiltins did not panic")
+		}
+	}()
+	registry.Register("one_too_many", noop)
+}
+
+func TestHashPreservesInsertionOrder(t *testing.T) {
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "b"}, &object.Integer{Value: 1})
+	hash.Set(&object.Integer{Value: 10}, &object.Integer{Value: 2})
+	hash.Set(object.TRUE, &object.Integer{Value: 3})
+	hash.Set(&object.String{Value: "a"}, &object.Integer{Value: 4})
+
+	// overwriting a key keeps its position, deleting and re-adding moves it to the end.
+	hash.Set(&object.String{Value: "b"}, &object.Integer{Value: 5})
+	hash.Delete(&object.Integer{Value: 10})
+	hash.Set(&object.Integer{Value: 10}, &object.Integer{Value: 6})
+
+	expected := "{b: 5, true: 3, a: 4, 10: 6}"
+	for i := 0; i < 10; i++ {
+		if hash.Inspect() != expected {
+			t.Fatalf("wrong inspect output. want=%q, got=%q", expected, hash.Inspect())
+		}
+	}
+
+	if hash.Len() != 4 {
+		t.Errorf("wrong length. want=4, got=%d", hash.Len())
+	}
+
+	if value, ok := hash.Get(&object.String{Value: "a"}); !ok || value.Inspect() != "4" {
+		t.Errorf("wrong value for key a. got=%v (%t)", value, ok)
+	}
+
+	hash.Delete(&object.String{Value: "missing"})
+	if hash.Len() != 4 {
+		t.Errorf("deleting a missing key changed the length")
+	}
+}
+
+func TestHashCopy(t *testing.T) {
+	hash := object.NewHash()
+	hash.Set(&object.Integer{Value: 1}, &object.Integer{Value: 1})
+
+	c := hash.Copy()
+	c.Set(&object.Integer{Value: 2}, &object.Integer{Value: 2})
+	c.Delete(&object.Integer{Value: 1})
+
+	if hash.Inspect() != "{1: 1}" {
+		t.Errorf("modifying a copy changed the original. got=%q", hash.Inspect())
+	}
+	if c.Inspect() != "{2: 2}" {
+		t.Errorf("wrong copy. got=%q", c.Inspect())
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	// every key lands in the same bucket, so lookups must tell keys apart by value.
+	collide := func(object.Hashable) object.HashKey {
+		return object.HashKey{Type: object.STRING_OBJ, Value: 1}
+	}
+
+	hash := object.NewHashWithHasher(collide)
+	hash.Set(&object.String{Value: "a"}, &object.Integer{Value: 1})
+	hash.Set(&object.String{Value: "b"}, &object.Integer{Value: 2})
+	hash.Set(&object.Integer{Value: 1}, &object.Integer{Value: 3})
+	hash.Set(&object.String{Value: "a"}, &object.Integer{Value: 4})
+
+	if hash.Len() != 3 {
+		t.Fatalf("colliding keys overwrote each other. got=%q", hash.Inspect())
+	}
+
+	tests := []struct {
+		key      object.Hashable
+		expected string
+	}{
+		{&object.String{Value: "a"}, "4"},
+		{&object.String{Value: "b"}, "2"},
+		{&object.Integer{Value: 1}, "3"},
+	}
+
+	for _, tt := range tests {
+		value, ok := hash.Get(tt.key)
+		if !ok {
+			t.Errorf("no value for key %s", tt.key.Inspect())
+			continue
+		}
+		if value.Inspect() != tt.expected {
+			t.Errorf("wrong value for key %s. want=%s, got=%s", tt.key.Inspect(), tt.expected, value.Inspect())
+		}
+	}
+
+	if _, ok := hash.Get(&object.String{Value: "c"}); ok {
+		t.Errorf("found a value for a key that was never set")
+	}
+
+	hash.Delete(&object.String{Value: "a"})
+	c := hash.Copy()
+	c.Set(&object.String{Value: "c"}, &object.Integer{Value: 5})
+
+	if hash.Inspect() != "{b: 2, 1: 3}" {
+		t.Errorf("wrong pairs after delete. got=%q", hash.Inspect())
+	}
+	if value, ok := c.Get(&object.Integer{Value: 1}); !ok || value.Inspect() != "3" {
+		t.Errorf("copy lost a colliding key. got=%q", c.Inspect())
+	}
+	if c.Inspect() != "{b: 2, 1: 3, c: 5}" {
+		t.Errorf("copy did not keep the hasher. got=%q", c.Inspect())
+	}
+}
+
+func TestCompositeHashKeys(t *testing.T) {
+	point := func(x, y int64) *object.Array {
+		return &object.Array{Elements: []object.Object{&object.Integer{Value: x}, &object.Integer{Value: y}}}
+	}
+
+	if point(1, 2).HashKey() != point(1, 2).HashKey() {
+		t.Errorf("arrays with same elements have different hash keys")
+	}
+	if point(1, 2).HashKey() == point(2, 1).HashKey() {
+		t.Errorf("arrays with elements in a different order have same hash key")
+	}
+
+	a := object.NewHash()
+	a.Set(&object.String{Value: "x"}, &object.Integer{Value: 1})
+	a.Set(&object.String{Value: "y"}, &object.Integer{Value: 2})
+	b := object.NewHash()
+	b.Set(&object.String{Value: "y"}, &object.Integer{Value: 2})
+	b.Set(&object.String{Value: "x"}, &object.Integer{Value: 1})
+
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("hashes with same pairs have different hash keys")
+	}
+
+	tests := []struct {
+		obj      object.Object
+		hashable bool
+	}{
+		{point(1, 2), true},
+		{&object.Array{Elements: []object.Object{point(1, 2), &object.String{Value: "a"}}}, true},
+		{a, true},
+		{&object.Array{Elements: []object.Object{&object.Function{}}}, false},
+		{&object.Array{Elements: []object.Object{object.NULL}}, false},
+		{&object.Function{}, false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := object.AsHashable(tt.obj); ok != tt.hashable {
+			t.Errorf("wrong hashability for %s. want=%t, got=%t", tt.obj.Inspect(), tt.hashable, ok)
+		}
+	}
+}
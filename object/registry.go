@@ -0,0 +1,129 @@
+package object
+
+import "fmt"
+
+// BuiltinRegistry holds the built-in functions visible to a single interpreter instance.
+//
+// The index of a builtin never changes once it has been registered, so bytecode compiled against a registry keeps
+// referring to the right function when builtins are later replaced or removed.
+// OpGetBuiltin encodes the index in two bytes, which limits a registry to MaxBuiltins builtins.
+type BuiltinRegistry struct {
+	definitions []BuiltinDefinition
+	indexes     map[string]int
+	modules     map[string]*Module
+	streams     *Streams
+}
+
+// MaxBuiltins is the number of builtins a registry can hold, as many as the operand of OpGetBuiltin can index.
+const MaxBuiltins = 1 << 16
+
+// NewBuiltinRegistry returns a registry populated with the default Builtins and native Modules, whose builtins use the
+// standard streams of the process.
+func NewBuiltinRegistry() *BuiltinRegistry {
+	r := &BuiltinRegistry{
+		indexes: make(map[string]int, len(Builtins)),
+		streams: ProcessStreams(),
+	}
+
+	for _, def := range Builtins {
+		r.Register(def.Name, def.Builtin.Fn)
+	}
+	for _, def := range Modules {
+		r.RegisterModule(def)
+	}
+
+	return r
+}
+
+// Register makes fn available under name, replacing any builtin previously registered with that name.
+// It panics when a new name would take the registry past MaxBuiltins.
+func (r *BuiltinRegistry) Register(name string, fn BuiltinFunction) {
+	builtin := &Builtin{Fn: fn}
+
+	if i, ok := r.indexes[name]; ok {
+		r.definitions[i].Builtin = builtin
+		return
+	}
+
+	if len(r.definitions) == MaxBuiltins {
+		panic(fmt.Sprintf("cannot register builtin %s: the registry already holds %d builtins", name, MaxBuiltins))
+	}
+
+	r.indexes[name] = len(r.definitions)
+	r.definitions = append(r.definitions, BuiltinDefinition{Name: name, Builtin: builtin})
+}
+
+// Remove hides the builtin registered under name. Its slot is kept so the other builtins keep their indexes.
+func (r *BuiltinRegistry) Remove(name string) {
+	if i, ok := r.indexes[name]; ok {
+		r.definitions[i].Builtin = nil
+	}
+}
+
+// Lookup returns the builtin registered under name.
+func (r *BuiltinRegistry) Lookup(name string) (*Builtin, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	i, ok := r.indexes[name]
+	if !ok || r.definitions[i].Builtin == nil {
+		return nil, false
+	}
+
+	return r.definitions[i].Builtin, true
+}
+
+// Get returns the builtin stored at index, or nil if there is none or it has been removed.
+func (r *BuiltinRegistry) Get(index int) *Builtin {
+	if index < 0 || index >= len(r.definitions) {
+		return nil
+	}
+
+	return r.definitions[index].Builtin
+}
+
+// Definitions returns every builtin slot in index order. Removed builtins are left in place with a nil Builtin.
+func (r *BuiltinRegistry) Definitions() []BuiltinDefinition {
+	definitions := make([]BuiltinDefinition, len(r.definitions))
+	copy(definitions, r.definitions)
+	return definitions
+}
+
+// RegisterModule makes the native module def importable by its name, replacing any module previously registered with
+// that name. Imports of the name resolve to the module instead of to a file.
+func (r *BuiltinRegistry) RegisterModule(def ModuleDefinition) {
+	exports := NewHash()
+	for _, builtin := range def.Builtins {
+		exports.Set(&String{Value: builtin.Name}, builtin.Builtin)
+	}
+
+	if r.modules == nil {
+		r.modules = make(map[string]*Module)
+	}
+	r.modules[def.Name] = &Module{Name: def.Name, Exports: exports}
+}
+
+// Module returns the native module registered under name.
+func (r *BuiltinRegistry) Module(name string) (*Module, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	mod, ok := r.modules[name]
+	return mod, ok
+}
+
+// SetStreams makes the builtins of the registry read from and write to streams.
+func (r *BuiltinRegistry) SetStreams(streams *Streams) {
+	r.streams = streams
+}
+
+// Streams returns the streams the builtins of the registry read from and write to.
+func (r *BuiltinRegistry) Streams() *Streams {
+	if r == nil || r.streams == nil {
+		return ProcessStreams()
+	}
+
+	return r.streams
+}
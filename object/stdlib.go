@@ -0,0 +1,463 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ModuleDefinition describes a native module: builtins that a script imports together by name, as in
+// `import "strings" as strings;`, instead of finding them among the global builtins.
+type ModuleDefinition struct {
+	Name     string
+	Builtins []BuiltinDefinition
+}
+
+// Modules are the native modules of the standard library, registered by NewBuiltinRegistry.
+// The os module they include cannot reach the host; use OSModule to register one that can.
+var Modules = []ModuleDefinition{
+	StringsModule,
+	MathModule,
+	TimeModule,
+	JSONModule,
+	OSModule(nil, nil),
+}
+
+// StringsModule operates on strings. Its builtins are also available globally.
+var StringsModule = ModuleDefinition{
+	Name: "strings",
+	Builtins: []BuiltinDefinition{
+		{Name: "split", Builtin: splitBuiltin("strings.split")},
+		{Name: "join", Builtin: joinBuiltin("strings.join")},
+		{Name: "trim", Builtin: stringTransform("strings.trim", strings.TrimSpace)},
+		{Name: "replace", Builtin: replaceBuiltin("strings.replace")},
+		{Name: "upper", Builtin: stringTransform("strings.upper", strings.ToUpper)},
+		{Name: "lower", Builtin: stringTransform("strings.lower", strings.ToLower)},
+		{Name: "contains", Builtin: stringPredicate("strings.contains", strings.Contains)},
+		{Name: "starts_with", Builtin: stringPredicate("strings.starts_with", strings.HasPrefix)},
+		{Name: "ends_with", Builtin: stringPredicate("strings.ends_with", strings.HasSuffix)},
+		{Name: "substr", Builtin: substrBuiltin("strings.substr")},
+	},
+}
+
+// MathModule does integer arithmetic beyond the operators.
+var MathModule = ModuleDefinition{
+	Name: "math",
+	Builtins: []BuiltinDefinition{
+		{
+			Name: "abs",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					if err := checkArgs("math.abs", args, INTEGER_OBJ); err != nil {
+						return err
+					}
+
+					n := args[0].(*Integer).Value
+					if n < 0 {
+						n = -n
+					}
+
+					return &Integer{Value: n}
+				},
+			},
+		},
+		{
+			Name: "min",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					return extremum("math.min", args, func(a, b int64) bool { return a < b })
+				},
+			},
+		},
+		{
+			Name: "max",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					return extremum("math.max", args, func(a, b int64) bool { return a > b })
+				},
+			},
+		},
+		{
+			Name: "pow",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					if err := checkArgs("math.pow", args, INTEGER_OBJ, INTEGER_OBJ); err != nil {
+						return err
+					}
+
+					base, exp := args[0].(*Integer).Value, args[1].(*Integer).Value
+					if exp < 0 {
+						return newError("negative exponent to `math.pow`: %d", exp)
+					}
+
+					// overflow wraps around, as it does for the arithmetic operators.
+					result := int64(1)
+					for ; exp > 0; exp >>= 1 {
+						if exp&1 == 1 {
+							result *= base
+						}
+						base *= base
+					}
+
+					return &Integer{Value: result}
+				},
+			},
+		},
+		{
+			Name: "sqrt",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					if err := checkArgs("math.sqrt", args, INTEGER_OBJ); err != nil {
+						return err
+					}
+
+					n := args[0].(*Integer).Value
+					if n < 0 {
+						return newError("negative argument to `math.sqrt`: %d", n)
+					}
+
+					// the integer square root, rounded down.
+					return &Integer{Value: new(big.Int).Sqrt(big.NewInt(n)).Int64()}
+				},
+			},
+		},
+	},
+}
+
+// TimeModule reads the clock. Times are integers counting milliseconds since the Unix epoch.
+var TimeModule = ModuleDefinition{
+	Name: "time",
+	Builtins: []BuiltinDefinition{
+		{
+			Name: "now",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					if err := checkArgs("time.now", args); err != nil {
+						return err
+					}
+
+					return &Integer{Value: time.Now().UnixMilli()}
+				},
+			},
+		},
+		{
+			Name: "sleep",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					if err := checkArgs("time.sleep", args, INTEGER_OBJ); err != nil {
+						return err
+					}
+
+					time.Sleep(time.Duration(args[0].(*Integer).Value) * time.Millisecond)
+
+					return NULL
+				},
+			},
+		},
+	},
+}
+
+// JSONModule converts between values and JSON text. Hashes keep their key order in both directions.
+var JSONModule = ModuleDefinition{
+	Name: "json",
+	Builtins: []BuiltinDefinition{
+		{
+			Name: "encode",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					if err := checkArgs("json.encode", args, ""); err != nil {
+						return err
+					}
+
+					var buf bytes.Buffer
+					if err := encodeJSON(&buf, args[0]); err != nil {
+						return newError("cannot encode JSON: %s", err)
+					}
+
+					return &String{Value: buf.String()}
+				},
+			},
+		},
+		{
+			Name: "decode",
+			Builtin: &Builtin{
+				Fn: func(ctx CallContext, args ...Object) Object {
+					if err := checkArgs("json.decode", args, STRING_OBJ); err != nil {
+						return err
+					}
+
+					dec := json.NewDecoder(strings.NewReader(args[0].(*String).Value))
+					dec.UseNumber()
+
+					value, err := decodeJSON(dec)
+					if err == nil {
+						if _, tokenErr := dec.Token(); tokenErr != io.EOF {
+							err = errors.New("unexpected data after top-level value")
+						}
+					}
+					if err != nil {
+						return newError("cannot decode JSON: %s", err)
+					}
+
+					return value
+				},
+			},
+		},
+	},
+}
+
+// OSModule returns an os module confined to what the host hands it: read_file, exists and list_dir see only fsys,
+// and getenv sees only env. A nil fsys makes every file access an error.
+func OSModule(fsys fs.FS, env map[string]string) ModuleDefinition {
+	openFS := func(name string) (fs.FS, *Error) {
+		if fsys == nil {
+			return nil, newError("`%s` is not available: no file system", name)
+		}
+		return fsys, nil
+	}
+
+	return ModuleDefinition{
+		Name: "os",
+		Builtins: []BuiltinDefinition{
+			{
+				Name: "read_file",
+				Builtin: &Builtin{
+					Fn: func(ctx CallContext, args ...Object) Object {
+						if err := checkArgs("os.read_file", args, STRING_OBJ); err != nil {
+							return err
+						}
+						fsys, err := openFS("os.read_file")
+						if err != nil {
+							return err
+						}
+
+						data, readErr := fs.ReadFile(fsys, args[0].(*String).Value)
+						if readErr != nil {
+							return newError("%s", readErr)
+						}
+
+						return &String{Value: string(data)}
+					},
+				},
+			},
+			{
+				Name: "exists",
+				Builtin: &Builtin{
+					Fn: func(ctx CallContext, args ...Object) Object {
+						if err := checkArgs("os.exists", args, STRING_OBJ); err != nil {
+							return err
+						}
+						fsys, err := openFS("os.exists")
+						if err != nil {
+							return err
+						}
+
+						_, statErr := fs.Stat(fsys, args[0].(*String).Value)
+						return NativeBoolToBooleanObject(statErr == nil)
+					},
+				},
+			},
+			{
+				Name: "list_dir",
+				Builtin: &Builtin{
+					Fn: func(ctx CallContext, args ...Object) Object {
+						if err := checkArgs("os.list_dir", args, STRING_OBJ); err != nil {
+							return err
+						}
+						fsys, err := openFS("os.list_dir")
+						if err != nil {
+							return err
+						}
+
+						entries, readErr := fs.ReadDir(fsys, args[0].(*String).Value)
+						if readErr != nil {
+							return newError("%s", readErr)
+						}
+
+						names := make([]Object, len(entries))
+						for i, entry := range entries {
+							names[i] = &String{Value: entry.Name()}
+						}
+
+						return &Array{Elements: names}
+					},
+				},
+			},
+			{
+				Name: "getenv",
+				Builtin: &Builtin{
+					Fn: func(ctx CallContext, args ...Object) Object {
+						if err := checkArgs("os.getenv", args, STRING_OBJ); err != nil {
+							return err
+						}
+
+						if value, ok := env[args[0].(*String).Value]; ok {
+							return &String{Value: value}
+						}
+
+						return NULL
+					},
+				},
+			},
+		},
+	}
+}
+
+// checkArgs reports an error unless args has one argument of each type in want, in order.
+// An empty type accepts an argument of any type.
+func checkArgs(name string, args []Object, want ...ObjectType) *Error {
+	if len(args) != len(want) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(want))
+	}
+
+	for i, t := range want {
+		if t != "" && args[i].Type() != t {
+			return newError("argument to `%s` must be %s, got %s", name, t, args[i].Type())
+		}
+	}
+
+	return nil
+}
+
+// extremum returns the integer argument that wins against every other one according to better.
+func extremum(name string, args []Object, better func(a, b int64) bool) Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want>=1")
+	}
+
+	var result int64
+	for i, arg := range args {
+		n, ok := arg.(*Integer)
+		if !ok {
+			return newError("argument to `%s` must be INTEGER, got %s", name, arg.Type())
+		}
+		if i == 0 || better(n.Value, result) {
+			result = n.Value
+		}
+	}
+
+	return &Integer{Value: result}
+}
+
+func encodeJSON(buf *bytes.Buffer, obj Object) error {
+	switch obj := obj.(type) {
+	case *Null:
+		buf.WriteString("null")
+
+	case *Boolean, *Integer:
+		buf.WriteString(obj.Inspect())
+
+	case *String:
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(obj.Value); err != nil {
+			return err
+		}
+		// Encode terminates the value with a newline.
+		buf.Truncate(buf.Len() - 1)
+
+	case *Array:
+		buf.WriteByte('[')
+		for i, e := range obj.Elements {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case *Hash:
+		buf.WriteByte('{')
+		for i, pair := range obj.Pairs() {
+			if _, ok := pair.Key.(*String); !ok {
+				return errors.New("object keys must be STRING, got " + string(pair.Key.Type()))
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, pair.Key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeJSON(buf, pair.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	default:
+		return errors.New("unsupported type " + string(obj.Type()))
+	}
+
+	return nil
+}
+
+func decodeJSON(dec *json.Decoder) (Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+
+	case bool:
+		return NativeBoolToBooleanObject(tok), nil
+
+	case string:
+		return &String{Value: tok}, nil
+
+	case json.Number:
+		n, err := tok.Int64()
+		if err != nil {
+			return nil, errors.New("number " + tok.String() + " is not an INTEGER")
+		}
+		return &Integer{Value: n}, nil
+
+	case json.Delim:
+		if tok == '[' {
+			elements := []Object{}
+			for dec.More() {
+				e, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, e)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &Array{Elements: elements}, nil
+		}
+
+		hash := NewHash()
+		for dec.More() {
+			key, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key.(*String), value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return hash, nil
+	}
+
+	return nil, errors.New("unexpected token")
+}
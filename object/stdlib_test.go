@@ -0,0 +1,54 @@
+package object_test
+
+import (
+	"monkey/object"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRegisterModule(t *testing.T) {
+	registry := object.NewBuiltinRegistry()
+	for _, name := range []string{"strings", "math", "time", "json", "os"} {
+		if _, ok := registry.Module(name); !ok {
+			t.Errorf("default registry is missing module %s", name)
+		}
+	}
+
+	fsys := fstest.MapFS{"data/a.txt": {Data: []byte("hello")}}
+	registry.RegisterModule(object.OSModule(fsys, map[string]string{"USER": "monkey"}))
+
+	os, _ := registry.Module("os")
+	call := func(name string, args ...object.Object) string {
+		fn, err := os.Get(&object.String{Value: name})
+		if err != nil {
+			t.Fatalf("os has no export %s", name)
+		}
+		return fn.(*object.Builtin).Fn(nil, args...).Inspect()
+	}
+
+	tests := []struct {
+		name     string
+		arg      string
+		expected string
+	}{
+		{"read_file", "data/a.txt", "hello"},
+		{"read_file", "data/b.txt", "ERROR: open data/b.txt: file does not exist"},
+		{"exists", "data/a.txt", "true"},
+		{"exists", "data/b.txt", "false"},
+		{"list_dir", "data", "[a.txt]"},
+		{"getenv", "USER", "monkey"},
+		{"getenv", "HOME", "null"},
+	}
+
+	for _, tt := range tests {
+		if got := call(tt.name, &object.String{Value: tt.arg}); got != tt.expected {
+			t.Errorf("wrong result for os.%s(%q). want=%q, got=%q", tt.name, tt.arg, tt.expected, got)
+		}
+	}
+
+	sandboxed, _ := object.NewBuiltinRegistry().Module("os")
+	readFile, _ := sandboxed.Get(&object.String{Value: "read_file"})
+	if got := readFile.(*object.Builtin).Fn(nil, &object.String{Value: "data/a.txt"}).Inspect(); got != "ERROR: `os.read_file` is not available: no file system" {
+		t.Errorf("changes to one registry leaked into a new registry. got=%q", got)
+	}
+}
@@ -0,0 +1,34 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// Streams are the standard input, output and error of an interpreter instance, which builtins such as puts and
+// read_line use instead of the process's own.
+type Streams struct {
+	Stdin  *bufio.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// processStdin is shared by every Streams reading the process's standard input, so that input buffered by one is not
+// lost to the others.
+var processStdin = bufio.NewReader(os.Stdin)
+
+// NewStreams returns Streams reading from stdin and writing to stdout and stderr.
+func NewStreams(stdin io.Reader, stdout, stderr io.Writer) *Streams {
+	reader, ok := stdin.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(stdin)
+	}
+
+	return &Streams{Stdin: reader, Stdout: stdout, Stderr: stderr}
+}
+
+// ProcessStreams returns Streams connected to the standard input, output and error of the process.
+func ProcessStreams() *Streams {
+	return &Streams{Stdin: processStdin, Stdout: os.Stdout, Stderr: os.Stderr}
+}
@@ -0,0 +1,155 @@
+package object
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Len returns the number of characters in the string, counting runes rather than bytes.
+func (s *String) Len() int {
+	return utf8.RuneCountInString(s.Value)
+}
+
+// Index returns the character at index i as a string of its own, or NULL when i is out of range.
+func (s *String) Index(i int64) Object {
+	if i < 0 {
+		return NULL
+	}
+
+	for _, r := range s.Value {
+		if i == 0 {
+			return &String{Value: string(r)}
+		}
+		i--
+	}
+
+	return NULL
+}
+
+// RepeatString returns s repeated count times, the result of `s * count`.
+func RepeatString(s *String, count int64) Object {
+	if count < 0 {
+		return newError("negative repeat count: %d", count)
+	}
+	if len(s.Value) > 0 && count > math.MaxInt32/int64(len(s.Value)) {
+		return newError("repeated string is too long")
+	}
+
+	return &String{Value: strings.Repeat(s.Value, int(count))}
+}
+
+// The builtins below are shared by the global builtins and the strings module, which report errors under different
+// names.
+
+func splitBuiltin(name string) *Builtin {
+	return &Builtin{
+		Fn: func(ctx CallContext, args ...Object) Object {
+			if err := checkArgs(name, args, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			parts := strings.Split(args[0].(*String).Value, args[1].(*String).Value)
+			elements := make([]Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &String{Value: part}
+			}
+
+			return &Array{Elements: elements}
+		},
+	}
+}
+
+func joinBuiltin(name string) *Builtin {
+	return &Builtin{
+		Fn: func(ctx CallContext, args ...Object) Object {
+			if err := checkArgs(name, args, ARRAY_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			elements := args[0].(*Array).Elements
+			parts := make([]string, len(elements))
+			for i, e := range elements {
+				str, ok := e.(*String)
+				if !ok {
+					return newError("elements joined by `%s` must be STRING, got %s", name, e.Type())
+				}
+				parts[i] = str.Value
+			}
+
+			return &String{Value: strings.Join(parts, args[1].(*String).Value)}
+		},
+	}
+}
+
+func replaceBuiltin(name string) *Builtin {
+	return &Builtin{
+		Fn: func(ctx CallContext, args ...Object) Object {
+			if err := checkArgs(name, args, STRING_OBJ, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			s, old, replacement := args[0].(*String).Value, args[1].(*String).Value, args[2].(*String).Value
+			return &String{Value: strings.ReplaceAll(s, old, replacement)}
+		},
+	}
+}
+
+func substrBuiltin(name string) *Builtin {
+	return &Builtin{
+		Fn: func(ctx CallContext, args ...Object) Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			if args[0].Type() != STRING_OBJ {
+				return newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+			}
+
+			// the bounds count characters and behave like those of `slice`.
+			runes := []rune(args[0].(*String).Value)
+			length := int64(len(runes))
+
+			bounds := []int64{0, length}
+			for i, arg := range args[1:] {
+				integer, ok := arg.(*Integer)
+				if !ok {
+					return newError("argument to `%s` must be INTEGER, got %s", name, arg.Type())
+				}
+				bounds[i] = clampIndex(integer.Value, length)
+			}
+
+			start, end := bounds[0], bounds[1]
+			if start > end {
+				start = end
+			}
+
+			return &String{Value: string(runes[start:end])}
+		},
+	}
+}
+
+// stringTransform returns a builtin that applies fn to its single string argument.
+func stringTransform(name string, fn func(string) string) *Builtin {
+	return &Builtin{
+		Fn: func(ctx CallContext, args ...Object) Object {
+			if err := checkArgs(name, args, STRING_OBJ); err != nil {
+				return err
+			}
+
+			return &String{Value: fn(args[0].(*String).Value)}
+		},
+	}
+}
+
+// stringPredicate returns a builtin that reports fn of its two string arguments.
+func stringPredicate(name string, fn func(s, substr string) bool) *Builtin {
+	return &Builtin{
+		Fn: func(ctx CallContext, args ...Object) Object {
+			if err := checkArgs(name, args, STRING_OBJ, STRING_OBJ); err != nil {
+				return err
+			}
+
+			return NativeBoolToBooleanObject(fn(args[0].(*String).Value, args[1].(*String).Value))
+		},
+	}
+}
@@ -0,0 +1,501 @@
+package parity_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/parity"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+// FuzzEngines generates a program from the fuzz input and checks that both engines produce the same result, or
+// errors of the same class.
+func FuzzEngines(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	f.Add([]byte("let add = fn(a, b) { a + b }; add(1, 2)"))
+	f.Add([]byte("let f = fn(a, b = a, ...rest) { rest }; f(1, 2, 3)"))
+	f.Add([]byte("f(...[1, 2], ...a); [...a, 1]; {...h, 1: 2}"))
+	f.Add([]byte{3, 9, 9, 9, 7, 1, 4, 200, 13, 5, 5, 2, 8, 8, 31, 40, 0, 0, 6})
+	f.Add([]byte{255, 254, 253, 252, 251, 250, 249, 248, 247, 246, 245, 244, 243})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		program := newGenerator(data).program()
+
+		evaluated, evalErr := parity.EvalProgram(program)
+		run, runErr := parity.RunProgram(program)
+
+		if summarize(evaluated, evalErr) != summarize(run, runErr) {
+			t.Fatalf("engines disagree on:\n%s\nevaluator=%s (%v)\nvm=%s (%v)",
+				render(program), summarize(evaluated, evalErr), evalErr, summarize(run, runErr), runErr)
+		}
+	})
+}
+
+// summarize describes a result so that it can be compared across engines. Errors are reduced to their class, the
+// part of the message before the first colon, and functions to their type, as each engine prints them differently.
+func summarize(result object.Object, err error) string {
+	if err != nil {
+		class, _, _ := strings.Cut(err.Error(), ":")
+		return "error: " + class
+	}
+	return summarizeObject(result)
+}
+
+func summarizeObject(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := make([]string, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elements[i] = summarizeObject(e)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+
+	case *object.Hash:
+		pairs := make([]string, 0, obj.Len())
+		for _, pair := range obj.Pairs() {
+			pairs = append(pairs, summarizeObject(pair.Key)+": "+summarizeObject(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+
+	case *object.Function, *object.Closure, *object.Builtin:
+		return string(obj.Type())
+
+	default:
+		return string(obj.Type()) + " " + obj.Inspect()
+	}
+}
+
+// generator builds well-formed programs by reading choices from a byte slice; once the bytes run out every choice
+// is zero, which always picks a terminating option.
+//
+// Programs only refer to names that are bound when the reference runs, and every name is bound once, so the
+// compiler's static resolution and the evaluator's dynamic lookup always agree. Return statements only appear in
+// statement position, where both engines give them the same meaning. Only function literals and functions bound by
+// an earlier let are called, so a function can never reach itself, and the number of calls in a program is capped
+// so that nested calls cannot multiply into a long running program.
+type generator struct {
+	data      []byte
+	pos       int
+	depth     int
+	names     int
+	calls     int
+	functions map[string]bool
+}
+
+const (
+	maxDepth = 4
+	maxCalls = 6
+)
+
+func newGenerator(data []byte) *generator {
+	return &generator{data: data, functions: make(map[string]bool)}
+}
+
+func (g *generator) choose(n int) int {
+	if g.pos >= len(g.data) {
+		return 0
+	}
+	b := g.data[g.pos]
+	g.pos++
+	return int(b) % n
+}
+
+func (g *generator) newName() string {
+	g.names++
+	return fmt.Sprintf("v%d", g.names)
+}
+
+func (g *generator) program() *ast.Program {
+	program := &ast.Program{}
+	var scope []string
+
+	for i := g.choose(4); i > 0; i-- {
+		program.Statements = append(program.Statements, g.letStatement(&scope))
+	}
+	for i := g.choose(3); i > 0; i-- {
+		program.Statements = append(program.Statements, g.statement(scope, true))
+	}
+	program.Statements = append(program.Statements, expressionStatement(g.expression(scope)))
+
+	return program
+}
+
+// letStatement returns a let statement binding a single name or destructuring its value with an array or hash pattern.
+func (g *generator) letStatement(scope *[]string) ast.Statement {
+	value := g.expression(*scope)
+	stmt := &ast.LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Value: value}
+
+	switch g.choose(4) {
+	case 1:
+		pattern := &ast.ArrayPattern{Token: token.Token{Type: token.LBRACKET, Literal: "["}}
+		for i := g.choose(3); i > 0; i-- {
+			pattern.Elements = append(pattern.Elements, identifier(g.newName()))
+			*scope = append(*scope, pattern.Elements[len(pattern.Elements)-1].Value)
+		}
+		if g.choose(2) == 1 {
+			pattern.Rest = identifier(g.newName())
+			*scope = append(*scope, pattern.Rest.Value)
+		}
+		stmt.Pattern = pattern
+
+	case 2:
+		pattern := &ast.HashPattern{Token: token.Token{Type: token.LBRACE, Literal: "{"}}
+		for i := g.choose(3); i > 0; i-- {
+			pattern.Keys = append(pattern.Keys, identifier(g.newName()))
+			*scope = append(*scope, pattern.Keys[len(pattern.Keys)-1].Value)
+		}
+		stmt.Pattern = pattern
+
+	default:
+		name := g.newName()
+		*scope = append(*scope, name)
+		if _, ok := value.(*ast.FunctionLiteral); ok {
+			g.functions[name] = true
+		}
+		stmt.Name = identifier(name)
+	}
+
+	return stmt
+}
+
+// statement returns an expression statement, an if, try or match statement, a throw statement or, when allowed, a
+// return statement.
+func (g *generator) statement(scope []string, allowReturn bool) ast.Statement {
+	switch g.choose(7) {
+	case 1:
+		return expressionStatement(g.ifExpression(scope, allowReturn))
+	case 2:
+		if allowReturn {
+			return &ast.ReturnStatement{
+				Token:       token.Token{Type: token.RETURN, Literal: "return"},
+				ReturnValue: g.expression(scope),
+			}
+		}
+	case 3:
+		return expressionStatement(g.tryExpression(scope, allowReturn))
+	case 4:
+		// only strings are thrown: the message of any other value is its Inspect output, which differs for functions.
+		value := []string{"a", "monkey"}[g.choose(2)]
+		return &ast.ThrowStatement{
+			Token: token.Token{Type: token.THROW, Literal: "throw"},
+			Value: &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value}, Value: value},
+		}
+	case 5:
+		return expressionStatement(g.matchExpression(scope))
+	}
+	return expressionStatement(g.expression(scope))
+}
+
+// block returns a block that always ends in an expression or return statement, so that it has a value.
+func (g *generator) block(scope []string, allowReturn bool) *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}}
+	for i := g.choose(3); i > 0; i-- {
+		block.Statements = append(block.Statements, g.statement(scope, allowReturn))
+	}
+	block.Statements = append(block.Statements, expressionStatement(g.expression(scope)))
+	return block
+}
+
+func (g *generator) ifExpression(scope []string, allowReturn bool) *ast.IfExpression {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	exp := &ast.IfExpression{
+		Token:       token.Token{Type: token.IF, Literal: "if"},
+		Condition:   g.expression(scope),
+		Consequence: g.block(scope, allowReturn),
+	}
+	if g.choose(2) == 1 {
+		exp.Alternative = g.block(scope, allowReturn)
+	}
+	return exp
+}
+
+// tryExpression returns a try expression with a catch block, a finally block or both. The catch parameter is only
+// in scope inside the catch block.
+func (g *generator) tryExpression(scope []string, allowReturn bool) *ast.TryExpression {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	exp := &ast.TryExpression{
+		Token: token.Token{Type: token.TRY, Literal: "try"},
+		Block: g.block(scope, allowReturn),
+	}
+	if g.choose(3) != 1 {
+		exp.Param = identifier(g.newName())
+		exp.Catch = g.block(append(append([]string(nil), scope...), exp.Param.Value), allowReturn)
+	}
+	if exp.Catch == nil || g.choose(2) == 1 {
+		exp.Finally = g.block(scope, allowReturn)
+	}
+	return exp
+}
+
+// matchExpression returns a match expression whose last arm is sometimes a wildcard.
+func (g *generator) matchExpression(scope []string) *ast.MatchExpression {
+	g.depth++
+	defer func() { g.depth-- }()
+
+	exp := &ast.MatchExpression{Token: token.Token{Type: token.MATCH, Literal: "match"}, Subject: g.expression(scope)}
+	for i := g.choose(3); i > 0; i-- {
+		exp.Arms = append(exp.Arms, &ast.MatchArm{Pattern: g.expression(scope), Body: g.expression(scope)})
+	}
+	if g.choose(2) == 1 {
+		exp.Arms = append(exp.Arms, &ast.MatchArm{Body: g.expression(scope)})
+	}
+	return exp
+}
+
+func (g *generator) expression(scope []string) ast.Expression {
+	if g.depth >= maxDepth {
+		return g.literal(scope)
+	}
+
+	g.depth++
+	defer func() { g.depth-- }()
+
+	switch g.choose(10) {
+	case 1:
+		operators := []string{"-", "!"}
+		op := operators[g.choose(len(operators))]
+		return &ast.PrefixExpression{Token: token.Token{Literal: op}, Operator: op, Right: g.expression(scope)}
+
+	case 2, 3:
+		operators := []string{"+", "-", "*", "/", "<", ">", "==", "!="}
+		op := operators[g.choose(len(operators))]
+		return &ast.InfixExpression{Token: token.Token{Literal: op}, Left: g.expression(scope), Operator: op, Right: g.expression(scope)}
+
+	case 4:
+		// an if in expression position never contains a return, see generator.
+		return g.ifExpression(scope, false)
+
+	case 5:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}}
+		for i := g.choose(4); i > 0; i-- {
+			array.Elements = append(array.Elements, g.element(scope))
+		}
+		return array
+
+	case 6:
+		hash := &ast.HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Pairs: make(map[ast.Expression]ast.Expression)}
+		for i := g.choose(3); i > 0; i-- {
+			key := g.element(scope)
+			hash.Keys = append(hash.Keys, key)
+			if _, ok := key.(*ast.SpreadExpression); !ok {
+				hash.Pairs[key] = g.expression(scope)
+			}
+		}
+		return hash
+
+	case 7:
+		return &ast.IndexExpression{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Left: g.expression(scope), Index: g.expression(scope)}
+
+	case 8:
+		return g.functionLiteral(scope)
+
+	case 9:
+		if g.calls >= maxCalls {
+			return g.literal(scope)
+		}
+		g.calls++
+
+		call := &ast.CallExpression{Token: token.Token{Type: token.LPAREN, Literal: "("}, Function: g.callee(scope)}
+		for i := g.choose(3); i > 0; i-- {
+			call.Args = append(call.Args, g.element(scope))
+		}
+		return call
+
+	default:
+		return g.literal(scope)
+	}
+}
+
+// element returns an expression for a call argument, array element or hash key, which is sometimes spread.
+func (g *generator) element(scope []string) ast.Expression {
+	if g.choose(5) == 1 {
+		return &ast.SpreadExpression{Token: token.Token{Type: token.ELLIPSIS, Literal: "..."}, Value: g.expression(scope)}
+	}
+	return g.expression(scope)
+}
+
+// callee returns a function literal or the name of a function bound earlier in scope.
+func (g *generator) callee(scope []string) ast.Expression {
+	var functions []string
+	for _, name := range scope {
+		if g.functions[name] {
+			functions = append(functions, name)
+		}
+	}
+
+	if len(functions) > 0 && g.choose(2) == 1 {
+		return identifier(functions[g.choose(len(functions))])
+	}
+	return g.functionLiteral(scope)
+}
+
+func (g *generator) functionLiteral(scope []string) ast.Expression {
+	fn := &ast.FunctionLiteral{Token: token.Token{Type: token.FUNCTION, Literal: "fn"}}
+
+	// once a parameter has a default, every parameter after it needs one too.
+	inner := append([]string(nil), scope...)
+	for i := g.choose(3); i > 0; i-- {
+		if fn.Defaults != nil || g.choose(3) == 1 {
+			if fn.Defaults == nil {
+				fn.Defaults = make([]ast.Expression, len(fn.Parameters))
+			}
+			fn.Defaults = append(fn.Defaults, g.expression(inner))
+		}
+
+		name := g.newName()
+		fn.Parameters = append(fn.Parameters, identifier(name))
+		inner = append(inner, name)
+	}
+	if g.choose(3) == 1 {
+		fn.Rest = identifier(g.newName())
+		inner = append(inner, fn.Rest.Value)
+	}
+
+	fn.Body = &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}}
+	for i := g.choose(3); i > 0; i-- {
+		fn.Body.Statements = append(fn.Body.Statements, g.letStatement(&inner))
+	}
+	fn.Body.Statements = append(fn.Body.Statements, g.block(inner, true).Statements...)
+
+	return fn
+}
+
+func (g *generator) literal(scope []string) ast.Expression {
+	switch g.choose(6) {
+	case 1:
+		value := g.choose(2) == 1
+		return &ast.Boolean{Token: token.Token{Literal: fmt.Sprint(value)}, Value: value}
+	case 2:
+		value := []string{"", "a", "monkey"}[g.choose(3)]
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value}, Value: value}
+	case 3:
+		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}
+	case 4, 5:
+		if len(scope) > 0 {
+			return identifier(scope[g.choose(len(scope))])
+		}
+	}
+
+	value := int64(g.choose(7)) - 2
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(value)}, Value: value}
+}
+
+func identifier(name string) *ast.Identifier {
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func expressionStatement(exp ast.Expression) ast.Statement {
+	return &ast.ExpressionStatement{Token: token.Token{Literal: exp.TokenLiteral()}, Expression: exp}
+}
+
+// render prints a generated program as Monkey source, so failures can be reproduced by hand.
+func render(node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.Program:
+		statements := make([]string, len(node.Statements))
+		for i, s := range node.Statements {
+			statements[i] = render(s)
+		}
+		return strings.Join(statements, "\n")
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			return "let " + node.Pattern.String() + " = " + render(node.Value) + ";"
+		}
+		return "let " + node.Name.Value + " = " + render(node.Value) + ";"
+	case *ast.ReturnStatement:
+		return "return " + render(node.ReturnValue) + ";"
+	case *ast.ExpressionStatement:
+		return render(node.Expression) + ";"
+	case *ast.BlockStatement:
+		statements := make([]string, len(node.Statements))
+		for i, s := range node.Statements {
+			statements[i] = render(s)
+		}
+		return "{ " + strings.Join(statements, " ") + " }"
+	case *ast.IfExpression:
+		out := "if (" + render(node.Condition) + ") " + render(node.Consequence)
+		if node.Alternative != nil {
+			out += " else " + render(node.Alternative)
+		}
+		return out
+	case *ast.TryExpression:
+		out := "try " + render(node.Block)
+		if node.Catch != nil {
+			out += " catch (" + node.Param.Value + ") " + render(node.Catch)
+		}
+		if node.Finally != nil {
+			out += " finally " + render(node.Finally)
+		}
+		return out
+	case *ast.MatchExpression:
+		arms := make([]string, len(node.Arms))
+		for i, arm := range node.Arms {
+			pattern := "_"
+			if arm.Pattern != nil {
+				pattern = render(arm.Pattern)
+			}
+			arms[i] = pattern + " => " + render(arm.Body)
+		}
+		return "match (" + render(node.Subject) + ") { " + strings.Join(arms, ", ") + " }"
+	case *ast.ThrowStatement:
+		return "throw " + render(node.Value) + ";"
+	case *ast.FunctionLiteral:
+		params := make([]string, len(node.Parameters))
+		for i, p := range node.Parameters {
+			params[i] = p.Value
+			if i < len(node.Defaults) && node.Defaults[i] != nil {
+				params[i] += " = " + render(node.Defaults[i])
+			}
+		}
+		if node.Rest != nil {
+			params = append(params, "..."+node.Rest.Value)
+		}
+		return "fn(" + strings.Join(params, ", ") + ") " + render(node.Body)
+	case *ast.CallExpression:
+		return "(" + render(node.Function) + ")(" + renderList(node.Args) + ")"
+	case *ast.ArrayLiteral:
+		return "[" + renderList(node.Elements) + "]"
+	case *ast.HashLiteral:
+		pairs := make([]string, len(node.Keys))
+		for i, k := range node.Keys {
+			if _, ok := k.(*ast.SpreadExpression); ok {
+				pairs[i] = render(k)
+				continue
+			}
+			pairs[i] = render(k) + ": " + render(node.Pairs[k])
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *ast.SpreadExpression:
+		return "..." + render(node.Value)
+	case *ast.IndexExpression:
+		return "(" + render(node.Left) + ")[" + render(node.Index) + "]"
+	case *ast.PrefixExpression:
+		return "(" + node.Operator + render(node.Right) + ")"
+	case *ast.InfixExpression:
+		return "(" + render(node.Left) + " " + node.Operator + " " + render(node.Right) + ")"
+	case *ast.StringLiteral:
+		return fmt.Sprintf("%q", node.Value)
+	case *ast.IntegerLiteral:
+		if node.Value < 0 {
+			return fmt.Sprintf("(%d)", node.Value)
+		}
+		return fmt.Sprint(node.Value)
+	default:
+		return node.String()
+	}
+}
+
+func renderList(exps []ast.Expression) string {
+	out := make([]string, len(exps))
+	for i, e := range exps {
+		out[i] = render(e)
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,130 @@
+// Package parity runs Monkey programs through both the evaluator and the compiler and VM, so that the two
+// engines can be checked against each other.
+package parity
+
+import (
+	"fmt"
+	"io/fs"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+)
+
+// Eval parses input and runs it with the tree-walking evaluator.
+// A runtime error is returned as the error rather than as the result; it is always an *object.Error.
+func Eval(input string) (object.Object, error) {
+	program, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return EvalProgram(program)
+}
+
+// EvalProgram runs an already parsed program with the tree-walking evaluator.
+func EvalProgram(program *ast.Program) (object.Object, error) {
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	if result == nil {
+		return evaluator.NULL, nil
+	}
+
+	return result, nil
+}
+
+// Run parses input, compiles it and runs the bytecode on the VM.
+// Runtime errors from the VM are *object.Error values, like those from Eval; compiler errors are plain Go errors.
+func Run(input string) (object.Object, error) {
+	program, err := parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return RunProgram(program)
+}
+
+// RunProgram compiles an already parsed program and runs the bytecode on the VM.
+func RunProgram(program *ast.Program) (object.Object, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+
+	result := machine.LastPoppedStackElem()
+	if result == nil {
+		return vm.Null, nil
+	}
+
+	return result, nil
+}
+
+// EvalFile runs the program at path in fsys with the tree-walking evaluator, resolving its imports within fsys.
+func EvalFile(fsys fs.FS, path string) (object.Object, error) {
+	loader := module.NewLoader(fsys)
+	program, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	env := object.NewEnvironment()
+	env.SetImporter(evaluator.NewImporter(loader, path))
+	result := evaluator.Eval(program, env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	if result == nil {
+		return evaluator.NULL, nil
+	}
+
+	return result, nil
+}
+
+// RunFile compiles the program at path in fsys, resolving its imports within fsys, and runs the bytecode on the VM.
+func RunFile(fsys fs.FS, path string) (object.Object, error) {
+	loader := module.NewLoader(fsys)
+	program, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	comp := compiler.New()
+	comp.SetLoader(loader, path)
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+
+	result := machine.LastPoppedStackElem()
+	if result == nil {
+		return vm.Null, nil
+	}
+
+	return result, nil
+}
+
+func parse(input string) (*ast.Program, error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser errors: %s", strings.Join(p.Errors(), "; "))
+	}
+
+	return program, nil
+}
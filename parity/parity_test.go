@@ -0,0 +1,330 @@
+package parity_test
+
+import (
+	"bytes"
+	"errors"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parity"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// corpus is run through both engines. A case expects either a result, compared by Inspect, or an error message.
+//
+// Two differences are by design and left out: a program ending in a let statement has no result in the evaluator,
+// and the compiler rejects undefined identifiers even in code that never runs.
+var corpus = []struct {
+	name     string
+	input    string
+	expected string
+	err      string
+}{
+	// arithmetic and comparison
+	{name: "integer arithmetic", input: "(5 + 10 * 2 + 15 / 3) * 2 + -10", expected: "50"},
+	{name: "integer division truncates", input: "7 / 2", expected: "3"},
+	{name: "division by zero", input: "1 / 0", err: "division by zero"},
+	{name: "less than", input: "1 < 2", expected: "true"},
+	{name: "greater than", input: "1 > 2", expected: "false"},
+	{name: "integer equality", input: "1 == 1", expected: "true"},
+	{name: "boolean equality", input: "(1 < 2) == true", expected: "true"},
+	{name: "bang operator", input: "!!5", expected: "true"},
+	{name: "mixed type equality", input: `1 == "1"`, expected: "false"},
+	{name: "adding integer and boolean", input: "5 + true", err: "type mismatch: INTEGER + BOOLEAN"},
+	{name: "comparing integer and string", input: `1 < "a"`, err: "type mismatch: INTEGER < STRING"},
+	{name: "adding booleans", input: "true + false", err: "unknown operator: BOOLEAN + BOOLEAN"},
+	{name: "negating a boolean", input: "-true", err: "unknown operator: -BOOLEAN"},
+	{name: "errors stop evaluation", input: "5 + true; 5", err: "type mismatch: INTEGER + BOOLEAN"},
+
+	// strings
+	{name: "string concatenation", input: `"Hello" + " " + "World"`, expected: "Hello World"},
+	{name: "string equality", input: `"a" == "a"`, expected: "true"},
+	{name: "subtracting strings", input: `"a" - "b"`, err: "unknown operator: STRING - STRING"},
+	{name: "ordering strings", input: `["a" < "b", "abc" > "abd"]`, expected: "[true, false]"},
+	{name: "string repetition", input: `"ab" * 2`, expected: "abab"},
+	{name: "string indexing", input: `let s = "naïve"; [s[2], s[5], len(s)]`, expected: "[ï, null, 5]"},
+	{name: "string builtins", input: `[split("a-b", "-"), join(["x", "y"], ""), trim(" x "), upper("a"), lower("B"), replace("a.b", ".", "/"), starts_with("ab", "a"), ends_with("ab", "a"), contains("ab", "b"), substr("abcd", 1, -1)]`, expected: "[[a, b], xy, x, A, b, a/b, true, false, true, bc]"},
+	{name: "template literals", input: "let h = {\"a\": 1}; `${h} has ${len(keys(h))} key${if (len(keys(h)) > 1) { \"s\" } else { \"\" }}`", expected: "{a: 1} has 1 key"},
+	{name: "template substitution errors", input: "`${-true}`", err: "unknown operator: -BOOLEAN"},
+	{name: "string builtin errors", input: `join([1], "")`, err: "elements joined by `join` must be STRING, got INTEGER"},
+
+	// conditionals
+	{name: "if with true condition", input: "if (1 < 2) { 10 } else { 20 }", expected: "10"},
+	{name: "if with false condition", input: "if (1 > 2) { 10 } else { 20 }", expected: "20"},
+	{name: "if without alternative", input: "if (false) { 10 }", expected: "null"},
+	{name: "null is falsy", input: "if (null) { 1 } else { 2 }", expected: "2"},
+	{name: "errors inside conditionals", input: "if (10 > 1) { true + false; }", err: "unknown operator: BOOLEAN + BOOLEAN"},
+
+	// bindings and functions
+	{name: "global bindings", input: "let a = 5; let b = a * 2; a + b", expected: "15"},
+	{name: "undefined identifier", input: "foobar", err: "identifier not found: foobar"},
+	{name: "function call", input: "let add = fn(a, b) { a + b }; add(1, 2)", expected: "3"},
+	{name: "immediately invoked function", input: "fn(x) { x * 2 }(4)", expected: "8"},
+	{name: "empty function body", input: "fn() {}()", expected: "null"},
+	{name: "early return", input: "let f = fn() { if (true) { return 1; } 2 }; f()", expected: "1"},
+	{name: "top level return", input: "return 5; 10", expected: "5"},
+	{name: "nested returns", input: "if (true) { if (true) { return 10; } return 1; }", expected: "10"},
+	{name: "too few arguments", input: "let f = fn(x) { x }; f()", err: "wrong number of arguments: want=1, got=0"},
+	{name: "too many arguments", input: "fn() { 1 }(1)", err: "wrong number of arguments: want=0, got=1"},
+	{name: "calling a non-function", input: "1(2)", err: "not a function: INTEGER"},
+	{name: "closures capture their environment", input: "let adder = fn(a) { fn(b) { a + b } }; adder(2)(3)", expected: "5"},
+	{name: "nested closures", input: "let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", expected: "6"},
+	{name: "recursion", input: "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", expected: "610"},
+	{name: "recursive closure", input: "let wrap = fn() { let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(5) }; wrap()", expected: "0"},
+	{name: "unbounded recursion", input: "let f = fn(n) { f(n + 1) }; f(0)", err: "stack overflow"},
+	{name: "functions compare by identity", input: "let f = fn() { 1 }; f == f", expected: "true"},
+
+	// arrays and hashes
+	{name: "array literal", input: "[1, 2 * 2, 3 + 3]", expected: "[1, 4, 6]"},
+	{name: "array index", input: "[1, 2, 3][1 + 1]", expected: "3"},
+	{name: "array index out of range", input: "[1, 2, 3][3]", expected: "null"},
+	{name: "negative array index", input: "[1][-1]", expected: "null"},
+	{name: "array equality", input: "[1, [2, 3]] == [1, [2, 3]]", expected: "true"},
+	{name: "adding arrays", input: "[1] + [2]", err: "unknown operator: ARRAY + ARRAY"},
+	{name: "indexing an integer", input: "1[0]", err: "index operator not supported: INTEGER"},
+	{name: "indexing an array with a string", input: `[1]["a"]`, err: "index operator not supported: ARRAY"},
+	{name: "hash literal keeps insertion order", input: `{"b": 1, "a": 2, 3: 3, true: 4}`, expected: "{b: 1, a: 2, 3: 3, true: 4}"},
+	{name: "hash index", input: `let h = {"one": 1, "two": 2}; h["t" + "wo"]`, expected: "2"},
+	{name: "missing hash key", input: `{"a": 1}["b"]`, expected: "null"},
+	{name: "composite hash keys", input: "{[1, 2]: 3}[[1, 2]]", expected: "3"},
+	{name: "hash equality ignores order", input: `{"a": 1, "b": 2} == {"b": 2, "a": 1}`, expected: "true"},
+	{name: "function as hash key", input: `{"a": 1}[fn(x) { x }]`, err: "unusable as hash key: FUNCTION"},
+
+	// builtins
+	{name: "len of string", input: `len("four")`, expected: "4"},
+	{name: "len of unsupported type", input: "len(1)", err: "argument to `len` not supported, got INTEGER"},
+	{name: "builtin arity", input: `len("a", "b")`, err: "wrong number of arguments. got=2, want=1"},
+	{name: "builtin errors stop the program", input: "len(1); 5", err: "argument to `len` not supported, got INTEGER"},
+	{name: "builtin errors inside arrays", input: "[1, len(1), 3]", err: "argument to `len` not supported, got INTEGER"},
+	{name: "builtin errors as arguments", input: "let f = fn(x) { 1 }; f(rest(1))", err: "argument to `rest` must be ARRAY, got INTEGER"},
+	{name: "first of empty array", input: "first([]) == null", expected: "true"},
+	{name: "rest of array", input: "rest([1, 2, 3])", expected: "[2, 3]"},
+	{name: "push copies the array", input: "let a = [1]; let b = push(a, 2); [a, b]", expected: "[[1], [1, 2]]"},
+	{name: "puts returns null", input: "puts()", expected: "null"},
+	{name: "map with a closure", input: "let n = 10; map([1, 2], fn(x) { x + n })", expected: "[11, 12]"},
+	{name: "reduce", input: "reduce(range(1, 5), fn(acc, x) { acc * x }, 1)", expected: "24"},
+	{name: "sort_by", input: "sort_by([3, 1, 2], fn(x) { -x })", expected: "[3, 2, 1]"},
+	{name: "callback errors", input: "map([1], fn(x) { x + true })", err: "type mismatch: INTEGER + BOOLEAN"},
+	{name: "callback arity", input: "map([1], fn(x, y) { x })", err: "wrong number of arguments: want=2, got=1"},
+
+	// default and rest parameters
+	{name: "default parameter", input: "let f = fn(x, y = x + 1) { [x, y] }; [f(1), f(1, 5)]", expected: "[[1, 2], [1, 5]]"},
+	{name: "defaults see only earlier parameters", input: "let b = 5; let f = fn(a = b, b = 1) { [a, b] }; [f(), f(1, 2), f()]", expected: "[[5, 1], [1, 2], [5, 1]]"},
+	{name: "default referring to a later parameter", input: "fn(a = b, b = 1) { a + 1 }()", err: "identifier not found: b"},
+	{name: "rest parameter", input: "let f = fn(a, ...rest) { [a, rest] }; [f(1), f(1, 2, 3)]", expected: "[[1, []], [1, [2, 3]]]"},
+	{name: "missing required argument", input: "fn(x, y = 1) { x }()", err: "wrong number of arguments: want=1..2, got=0"},
+	{name: "missing variadic argument", input: "fn(x, ...r) { x }()", err: "wrong number of arguments: want=1+, got=0"},
+
+	// spread
+	{name: "spread arguments", input: "let f = fn(a, b, ...rest) { [a, b, rest] }; f(...[1], ...[2, 3, 4])", expected: "[1, 2, [3, 4]]"},
+	{name: "spread larger than the stack", input: "let f = fn(...r) { len(r) }; f(...range(3000))", expected: "3000"},
+	{name: "spread larger than the stack into a builtin", input: `import "math" as m; m.max(...range(3000))`, expected: "2999"},
+	{name: "array spread", input: "let a = [2, 3]; [1, ...a, ...[], 4]", expected: "[1, 2, 3, 4]"},
+	{name: "hash spread keeps insertion order", input: `let base = {"a": 1, "b": 2}; {"c": 0, ...base, "a": 3}`, expected: "{c: 0, a: 3, b: 2}"},
+	{name: "spreading a hash into an array", input: "[...{}]", err: "spread element must be ARRAY, got HASH"},
+	{name: "unusable key before a spread", input: `{fn() {}: 1, ...1}`, err: "unusable as hash key: FUNCTION"},
+
+	// destructuring
+	{name: "array destructuring", input: "let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]", expected: "[1, 2, [3, 4]]"},
+	{name: "short array destructuring", input: "let [a, b, ...rest] = [1]; [a, b, rest]", expected: "[1, null, []]"},
+	{name: "hash destructuring", input: `let {name, age} = {"age": 30, "name": "Ivan"}; [name, age]`, expected: "[Ivan, 30]"},
+	{name: "destructuring a non-array", input: "let [a] = 1; a", err: "index operator not supported: INTEGER"},
+	{name: "rest of a hash", input: "let [a, ...r] = {}; r", err: "rest element must be ARRAY, got HASH"},
+
+	// else if and match
+	{name: "else if", input: "let x = 5; if (x < 3) { 1 } else if (x < 10) { 2 } else { 3 }", expected: "2"},
+	{name: "else if without a match", input: "if (false) { 1 } else if (false) { 2 }", expected: "null"},
+	{name: "match", input: `let f = fn(x) { match (x) { 1 => "one", "a" => "letter", _ => "other" } }; [f(1), f("a"), f([])]`, expected: "[one, letter, other]"},
+	{name: "match without a matching arm", input: "match (3) { 1 => 2 }", expected: "null"},
+	{name: "match arm blocks", input: `let f = fn(x) { match (x) { 1 => { let y = x + 1; y * 2 }, 2 => { let z = 0; }, _ => ({"x": x}) } }; [f(1), f(2), f(3)]`, expected: "[4, null, {x: 3}]"},
+	{name: "return from a match arm block", input: "let f = fn(x) { match (x) { 1 => { return 10 } }; 20 }; [f(1), f(2)]", expected: "[10, 20]"},
+	{name: "match pattern errors", input: "match (2) { 1 / 0 => 1 }", err: "division by zero"},
+
+	// exceptions
+	{name: "caught throw", input: `try { throw "boom" } catch (e) { e }`, expected: "{message: boom, stack: [], value: boom}"},
+	{name: "caught runtime error", input: `try { [1] + 1 } catch (e) { e["message"] }`, expected: "type mismatch: ARRAY + INTEGER"},
+	{name: "error stack", input: `let f = fn() { throw "x" }; let g = fn() { f() }; try { g() } catch (e) { e["stack"] }`, expected: "[f, g]"},
+	{name: "finally overrides with return", input: `let f = fn() { try { return 1 } finally { return 2 } }; f()`, expected: "2"},
+	{name: "rethrow from finally", input: `try { throw "x" } finally { 1 }`, err: "x"},
+	{name: "uncaught throw", input: `let f = fn() { throw 1 }; f()`, err: "1"},
+	{name: "catch parameter is scoped", input: `let e = 5; let x = try { throw 1 } catch (e) { let y = e["value"]; 2 }; [e, x, y]`, expected: "[5, 2, 1]"},
+	{name: "catch parameter in closures", input: `let f = try { throw 1 } catch (e) { fn() { e["value"] } }; f()`, expected: "1"},
+	{name: "catch parameter is not visible after the catch block", input: `try { throw 1 } catch (e) { 2 }; e`, err: "identifier not found: e"},
+	{name: "throw through builtins", input: `try { map([1], fn(x) { throw x }) } catch (e) { e }`, expected: "{message: 1, stack: [<anonymous>], value: 1}"},
+	{name: "type builtins", input: `[type(fn() {}), type(len), type({}), str([1, "a"]), int("12") + 1, bool(null), is_function(fn() {}), is_string(1)]`, expected: "[FUNCTION, BUILTIN, HASH, [1, a], 13, false, true, false]"},
+	{name: "conversion errors", input: `try { parse_int("ff") } catch (e) { e["message"] }`, expected: `invalid integer "ff" in base 10`},
+	{name: "format", input: `let rows = [["apples", 3], ["kiwis", 12]]; map(rows, fn(r) { format("%-8s%4d", r[0], r[1]) })`, expected: "[apples     3, kiwis     12]"},
+	{name: "format errors", input: `format("%d%%", true)`, err: "format verb %d does not accept BOOLEAN"},
+	{name: "hash builtins", input: `let h = merge({"a": 1}, {"b": 2}); [keys(h), values(delete(h, "a")), has(h, "b")]`, expected: `[[a, b], [2], true]`},
+	{name: "each over hash", input: "each({1: 2}, fn(k, v) { k + v })", expected: "null"},
+
+	// standard library
+	{name: "strings module", input: `import "strings" as s; [s.split("a,b", ","), s.join(["a", "b"], "-"), s.trim("  x "), s.replace("aba", "a", "c"), s.upper("a"), s.lower("B"), s.contains("abc", "bc")]`, expected: "[[a, b], a-b, x, cbc, A, b, true]"},
+	{name: "strings argument errors", input: `import "strings" as s; s.join([1], "")`, err: "elements joined by `strings.join` must be STRING, got INTEGER"},
+	{name: "math module", input: `import "math" as m; [m.abs(-3), m.min(3, 1, 2), m.max(3, 1, 2), m.pow(2, 10), m.sqrt(17)]`, expected: "[3, 1, 3, 1024, 4]"},
+	{name: "math argument errors", input: `import "math" as m; m.sqrt(-1)`, err: "negative argument to `math.sqrt`: -1"},
+	{name: "time module", input: `import "time" as t; let start = t.now(); [t.sleep(1), is_integer(start), !(start > t.now())]`, expected: "[null, true, true]"},
+	{name: "json module", input: `import "json" as j; let text = j.encode({"b": [1, true, null], "a": "x"}); [text, j.decode(text), j.decode(" [1, {}] ")]`, expected: `[{"b":[1,true,null],"a":"x"}, {b: [1, true, null], a: x}, [1, {}]]`},
+	{name: "json errors", input: `import "json" as j; j.decode("[1.5]")`, err: "cannot decode JSON: number 1.5 is not an INTEGER"},
+	{name: "json encoding errors", input: `import "json" as j; j.encode({1: 2})`, err: "cannot encode JSON: object keys must be STRING, got INTEGER"},
+	{name: "os module is sandboxed", input: `import "os" as os; [os.getenv("HOME"), try { os.read_file("/etc/passwd") } catch (e) { e["message"] }]`, expected: "[null, `os.read_file` is not available: no file system]"},
+	{name: "native modules are shared", input: `import "math" as a; import "math" as b; [a == b, a]`, expected: "[true, <module math>]"},
+	{name: "missing native exports", input: `import "math" as m; m.floor`, err: "module math has no export floor"},
+}
+
+func TestEnginesAgree(t *testing.T) {
+	for _, tt := range corpus {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluated, evalErr := parity.Eval(tt.input)
+			run, runErr := parity.Run(tt.input)
+
+			checkResult(t, "evaluator", evaluated, evalErr, tt.expected, tt.err)
+			checkResult(t, "vm", run, runErr, tt.expected, tt.err)
+
+			if describe(evaluated, evalErr) != describe(run, runErr) {
+				t.Errorf("engines disagree. evaluator=%s, vm=%s", describe(evaluated, evalErr), describe(run, runErr))
+			}
+		})
+	}
+}
+
+// modules is the file system the programs in TestImports are read from.
+var modules = fstest.MapFS{
+	"lib/math.mk":     {Data: []byte(`let pi = 3; let square = fn(x) { x * x }; let [one, two] = [1, 2];`)},
+	"lib/counter.mk":  {Data: []byte(`import "math.mk" as math; let next = fn() { math.pi + 1 };`)},
+	"lib/private.mk":  {Data: []byte(`let hidden = 1; if (true) { let local = 2; } hidden + 1`)},
+	"lib/early.mk":    {Data: []byte(`let a = 1; return 5; let b = 2;`)},
+	"lib/failing.mk":  {Data: []byte(`let f = fn() { 1 / 0 }; f();`)},
+	"lib/broken.mk":   {Data: []byte(`let x = ;`)},
+	"cycle/a.mk":      {Data: []byte(`import "b.mk" as b; 1`)},
+	"cycle/b.mk":      {Data: []byte(`import "a.mk" as a; 2`)},
+	"nested/dir/x.mk": {Data: []byte(`import "../../lib/math.mk" as m; let v = m.pi;`)},
+}
+
+func TestImports(t *testing.T) {
+	tests := []struct {
+		name     string
+		main     string
+		expected string
+		err      string
+	}{
+		{name: "exported bindings", main: `import "lib/math.mk" as math; [math.pi, math["square"](4), math.one + math.two]`, expected: "[3, 16, 3]"},
+		{name: "module value", main: `import "lib/math.mk" as math; math`, expected: "<module lib/math.mk>"},
+		{name: "imports are relative to the importing file", main: `import "lib/counter.mk" as c; c.next()`, expected: "4"},
+		{name: "parent directories", main: `import "nested/dir/x.mk" as x; x.v`, expected: "3"},
+		{name: "modules are loaded once", main: `import "lib/math.mk" as a; import "lib/counter.mk" as c; import "lib/math.mk" as b; a == b`, expected: "true"},
+		{name: "functions use their module's bindings", main: `let pi = 100; import "lib/counter.mk" as c; c.next()`, expected: "4"},
+		{name: "only top level lets are exported", main: `import "lib/private.mk" as p; p.local`, err: `module lib/private.mk has no export local`},
+		{name: "return ends a module early", main: `import "lib/early.mk" as e; e.b`, err: `module lib/early.mk has no export b`},
+		{name: "bindings before an early return", main: `import "lib/early.mk" as e; e.a`, expected: "1"},
+		{name: "imports inside functions", main: `let load = fn() { import "lib/math.mk" as m; m.pi }; load()`, expected: "3"},
+		{name: "errors raised while loading", main: `try { import "lib/failing.mk" as f; } catch (e) { e["stack"] }`, expected: "[f, lib/failing.mk]"},
+		{name: "import cycles", main: `import "cycle/a.mk" as a;`, err: "import cycle: cycle/a.mk -> cycle/b.mk -> cycle/a.mk"},
+		{name: "missing modules", main: `import "lib/nope.mk" as n;`, err: "module not found: lib/nope.mk"},
+		{name: "parser errors", main: `import "lib/broken.mk" as b;`, err: "parser errors in module lib/broken.mk: no prefix parse function for ; found"},
+		{name: "paths outside the root", main: `import "../x.mk" as x;`, err: "invalid module path: ../x.mk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"main.mk": {Data: []byte(tt.main)}}
+			for name, file := range modules {
+				fsys[name] = file
+			}
+
+			evaluated, evalErr := parity.EvalFile(fsys, "main.mk")
+			run, runErr := parity.RunFile(fsys, "main.mk")
+
+			checkResult(t, "evaluator", evaluated, evalErr, tt.expected, tt.err)
+			checkResult(t, "vm", run, runErr, tt.expected, tt.err)
+		})
+	}
+}
+
+func TestRuntimeErrorsAreObjectErrors(t *testing.T) {
+	inputs := []string{"1 / 0", "-true", "len(1)", "[1, first(1)]", "fn(x) { x }()", `{fn() { 1 }: 1}`}
+
+	for _, input := range inputs {
+		for engine, run := range map[string]func(string) (object.Object, error){"evaluator": parity.Eval, "vm": parity.Run} {
+			_, err := run(input)
+			var errObj *object.Error
+			if !errors.As(err, &errObj) {
+				t.Errorf("%s: %q: expected an *object.Error, got=%T (%v)", engine, input, err, err)
+			}
+		}
+	}
+}
+
+func checkResult(t *testing.T, engine string, result object.Object, err error, expected, expectedErr string) {
+	t.Helper()
+
+	if expectedErr != "" {
+		if err == nil {
+			t.Errorf("%s: expected error %q, got=%s", engine, expectedErr, result.Inspect())
+			return
+		}
+		if err.Error() != expectedErr {
+			t.Errorf("%s: wrong error. want=%q, got=%q", engine, expectedErr, err)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Errorf("%s: unexpected error: %s", engine, err)
+		return
+	}
+	if result.Inspect() != expected {
+		t.Errorf("%s: wrong result. want=%q, got=%q", engine, expected, result.Inspect())
+	}
+}
+
+func describe(result object.Object, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(result.Type()) + ": " + result.Inspect()
+}
+
+func TestStreams(t *testing.T) {
+	input := `let name = read_line(); puts("hi " + name); print("a", 1); eputs("oops"); map([1, 2], puts); [read_line(), read_line()]`
+
+	engines := map[string]func(builtins *object.BuiltinRegistry) object.Object{
+		"evaluator": func(builtins *object.BuiltinRegistry) object.Object {
+			program := parser.New(lexer.New(input)).ParseProgram()
+			return evaluator.Eval(program, object.NewEnvironmentWithBuiltins(builtins))
+		},
+		"vm": func(builtins *object.BuiltinRegistry) object.Object {
+			comp := compiler.NewWithBuiltins(builtins)
+			if err := comp.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+			machine := vm.New(comp.Bytecode())
+			if err := machine.Run(); err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+			return machine.LastPoppedStackElem()
+		},
+	}
+
+	for name, run := range engines {
+		var stdout, stderr bytes.Buffer
+		builtins := object.NewBuiltinRegistry()
+		builtins.SetStreams(object.NewStreams(strings.NewReader("Jo\r\nlast"), &stdout, &stderr))
+
+		// the last line has no line ending, and reading after it gives null.
+		if result := run(builtins); result.Inspect() != "[last, null]" {
+			t.Errorf("%s: wrong result. got=%q", name, result.Inspect())
+		}
+		if stdout.String() != "hi Jo\na11\n2\n" {
+			t.Errorf("%s: wrong stdout. got=%q", name, stdout.String())
+		}
+		if stderr.String() != "oops\n" {
+			t.Errorf("%s: wrong stderr. got=%q", name, stderr.String())
+		}
+	}
+}
@@ -20,6 +20,7 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 const (
@@ -62,18 +63,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE, p.parseTemplateLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseTemplateLiteral)
 
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 
@@ -89,6 +95,7 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseDotExpression)
 
 	// Read two tokens, so curToken and peekToken are both set.
 	p.nextToken()
@@ -129,6 +136,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -185,16 +196,123 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+// parseArrayPattern parses the names of an array pattern, ending with an optional ...rest.
+// It returns a nil Pattern, rather than a nil *ast.ArrayPattern, on error.
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			// the rest has to be the last element
+			break
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+// parseHashPattern parses the names of a hash pattern.
+// It returns a nil Pattern, rather than a nil *ast.HashPattern, on error.
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseImportStatement parses import "path" as name. The 'as' is only special in this position, so it can still be
+// used as a name elsewhere.
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
+	if p.curToken.Literal != "as" {
+		p.errors = append(p.errors, fmt.Sprintf("expected as after import path, got %s instead", p.curToken.Literal))
+		return nil
+	}
 
-	stmt.Name = &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseLetStatement() *ast.LetStatement {
+	stmt := &ast.LetStatement{Token: p.curToken}
+
+	switch {
+	case p.peekTokenIs(token.LBRACKET):
+		p.nextToken()
+		stmt.Pattern = p.parseArrayPattern()
+	case p.peekTokenIs(token.LBRACE):
+		p.nextToken()
+		stmt.Pattern = p.parseHashPattern()
+	case p.expectPeek(token.IDENT):
+		stmt.Name = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
+	}
+
+	if stmt.Name == nil && stmt.Pattern == nil {
+		return nil
 	}
 
 	if !p.expectPeek(token.ASSIGN) {
@@ -205,7 +323,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
 		fl.Name = stmt.Name.Value
 	}
 
@@ -321,6 +439,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -358,6 +480,19 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			// `else if` is parsed as an else block holding just the next if expression.
+			p.nextToken()
+			block := &ast.BlockStatement{Token: p.curToken}
+			stmt := &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseIfExpression()}
+			if stmt.Expression == nil {
+				return nil
+			}
+			block.Statements = []ast.Statement{stmt}
+			expression.Alternative = block
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -368,6 +503,111 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := &ast.MatchArm{}
+		if !p.curTokenIs(token.IDENT) || p.curToken.Literal != "_" {
+			arm.Pattern = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		p.nextToken()
+		if p.curTokenIs(token.LBRACE) {
+			arm.Body = p.parseBlockStatement()
+		} else if body := p.parseExpression(LOWEST); body != nil {
+			arm.Body = body
+		} else {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errors = append(p.errors, "expected catch or finally after try block")
+		return nil
+	}
+
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -393,7 +633,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -404,31 +646,55 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	var identifiers []*ast.Identifier
+// parseFunctionParameters parses the parameter list of lit, including its closing parenthesis.
+// Parameters with a default value have to follow those without one, and a ...rest parameter has to come last.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	hasDefaults := false
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
-	}
+	for !p.peekTokenIs(token.RPAREN) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
 
-	p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			value = p.parseExpression(LOWEST)
+			if value == nil {
+				return false
+			}
+			if !hasDefaults {
+				// parameters before the first default have none.
+				lit.Defaults = make([]ast.Expression, len(lit.Parameters))
+				hasDefaults = true
+			}
+		} else if hasDefaults {
+			p.errors = append(p.errors, fmt.Sprintf("parameter %s without a default follows a parameter with one", ident.Value))
+			return false
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
+		lit.Parameters = append(lit.Parameters, ident)
+		if hasDefaults {
+			lit.Defaults = append(lit.Defaults, value)
+		}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return false
+		}
 	}
 
-	return identifiers
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -444,6 +710,29 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	}
 }
 
+// parseTemplateLiteral parses the expression substituted after each part of the template, until the part that ends it.
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	lit := &ast.TemplateLiteral{Token: p.curToken, Strings: []string{p.curToken.Literal}}
+
+	for !p.curTokenIs(token.TEMPLATE) && !p.curTokenIs(token.TEMPLATE_TAIL) {
+		p.nextToken()
+		expression := p.parseExpression(LOWEST)
+		if expression == nil {
+			return nil
+		}
+		lit.Expressions = append(lit.Expressions, expression)
+
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.errors = append(p.errors, fmt.Sprintf("expected } after template substitution, got %s instead", p.peekToken.Type))
+			return nil
+		}
+		p.nextToken()
+		lit.Strings = append(lit.Strings, p.curToken.Literal)
+	}
+
+	return lit
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -461,12 +750,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -476,6 +765,18 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseElement parses an element of an expression list, which may be spread: ...args
+func (p *Parser) parseElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
@@ -489,6 +790,18 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseDotExpression parses left.name as the index expression left["name"].
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Index = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -497,6 +810,14 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	for !p.peekTokenIs(token.RBRACE) {
 		// go to the next token
 		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			// a spread copies the pairs of another hash and has no value of its own.
+			hash.Keys = append(hash.Keys, p.parseElement())
+			if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			continue
+		}
 		key := p.parseExpression(LOWEST)
 
 		// if not a colon after key return nil
@@ -508,6 +829,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
+		hash.Keys = append(hash.Keys, key)
 		hash.Pairs[key] = value
 
 		// next token should either be a rbrace or comma
@@ -5,6 +5,7 @@ import (
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/parser"
+	"reflect"
 	"testing"
 )
 
@@ -42,6 +43,72 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = pair;", "let [a, b] = pair;"},
+		{"let [a, b, ...rest] = [1, 2, 3];", "let [a, b, ...rest] = [1, 2, 3];"},
+		{"let [...all] = xs;", "let [...all] = xs;"},
+		{"let [] = xs;", "let [] = xs;"},
+		{"let [a, b,] = xs;", "let [a, b] = xs;"},
+		{`let {name, age} = person;`, "let {name, age} = person;"},
+		{`let {} = person;`, "let {} = person;"},
+	}
+
+	for _, tt := range tests {
+		program := setupProgramForTest(t, tt.input)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Fatalf("let statement has no pattern. got name=%v, pattern=%v", stmt.Name, stmt.Pattern)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+
+	program := setupProgramForTest(t, "let [a, ...rest] = xs;")
+	pattern := program.Statements[0].(*ast.LetStatement).Pattern.(*ast.ArrayPattern)
+	if len(pattern.Elements) != 1 || !testIdentifier(t, pattern.Elements[0], "a") {
+		t.Errorf("wrong pattern elements. got=%v", pattern.Elements)
+	}
+	if !testIdentifier(t, pattern.Rest, "rest") {
+		return
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, ...rest, b] = xs;", "expected next token to be ], got , instead"},
+		{"let [1] = xs;", "expected next token to be IDENT, got INT instead"},
+		{"let [a b] = xs;", "expected next token to be ,, got IDENT instead"},
+		{`let {"a"} = xs;`, "expected next token to be IDENT, got STRING instead"},
+		{"let [...] = xs;", "expected next token to be IDENT, got ] instead"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first=%q, got=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -380,6 +447,99 @@ func TestBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestNullLiteral(t *testing.T) {
+	program := setupProgramForTest(t, "null;")
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	null, ok := stmt.Expression.(*ast.NullLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.NullLiteral. got=%T", stmt.Expression)
+	}
+	if null.TokenLiteral() != "null" {
+		t.Errorf("null.TokenLiteral not %q. got=%q", "null", null.TokenLiteral())
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input      string
+		hasCatch   bool
+		hasFinally bool
+		expected   string
+	}{
+		{`try { x } catch (e) { y }`, true, false, "try {x} catch (e) {y}"},
+		{`try { x } finally { z }`, false, true, "try {x} finally {z}"},
+		{`try { x } catch (e) { y } finally { z }`, true, true, "try {x} catch (e) {y} finally {z}"},
+	}
+
+	for _, tt := range tests {
+		program := setupProgramForTest(t, tt.input)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if (exp.Catch != nil) != tt.hasCatch {
+			t.Errorf("exp.Catch present=%t, want=%t", exp.Catch != nil, tt.hasCatch)
+		}
+		if tt.hasCatch && !testIdentifier(t, exp.Param, "e") {
+			return
+		}
+		if (exp.Finally != nil) != tt.hasFinally {
+			t.Errorf("exp.Finally present=%t, want=%t", exp.Finally != nil, tt.hasFinally)
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() wrong. want=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { x }`, "expected catch or finally after try block"},
+		{`try { x } catch { y }`, "expected next token to be (, got { instead"},
+		{`try { x } catch (1) { y }`, "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first=%q, got=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	program := setupProgramForTest(t, `throw "boom";`)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if stmt.TokenLiteral() != "throw" {
+		t.Errorf("stmt.TokenLiteral not 'throw'. got=%q", stmt.TokenLiteral())
+	}
+	if stmt.String() != `throw boom;` {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 
@@ -482,6 +642,113 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	program := setupProgramForTest(t, `if (x < y) { x } else if (x > y) { y } else { z }`)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("alternative is not 1 statement. got=%d", len(exp.Alternative.Statements))
+	}
+
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("alternative statement is not ast.ExpressionStatement. got=%T", exp.Alternative.Statements[0])
+	}
+
+	elseIf, ok := alternative.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression. got=%T", alternative.Expression)
+	}
+
+	if !testInfixExpression(t, elseIf.Condition, "x", ">", "y") {
+		return
+	}
+	if elseIf.Alternative == nil || elseIf.Alternative.String() != "z" {
+		t.Errorf("else if has wrong alternative. got=%v", elseIf.Alternative)
+	}
+	if exp.String() != "if(x < y) xelse if(x > y) yelse z" {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) { 1 => "one", a + b => [a], _ => null, }`
+
+	program := setupProgramForTest(t, input)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Subject, "x") {
+		return
+	}
+	if len(exp.Arms) != 3 {
+		t.Fatalf("exp.Arms does not contain 3 arms. got=%d", len(exp.Arms))
+	}
+	if !testLiteralExpression(t, exp.Arms[0].Pattern, 1) {
+		return
+	}
+	if !testInfixExpression(t, exp.Arms[1].Pattern, "a", "+", "b") {
+		return
+	}
+	if exp.Arms[2].Pattern != nil {
+		t.Errorf("wildcard arm has a pattern. got=%s", exp.Arms[2].Pattern)
+	}
+	if exp.String() != `match (x) {1 => one, (a + b) => [a], _ => null}` {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+}
+
+func TestMatchArmBlocks(t *testing.T) {
+	input := `match (x) { 1 => { let y = 2; y }, _ => ({"a": 1}) }`
+
+	program := setupProgramForTest(t, input)
+
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	block, ok := exp.Arms[0].Body.(*ast.BlockStatement)
+	if !ok {
+		t.Fatalf("exp.Arms[0].Body is not *ast.BlockStatement. got=%T", exp.Arms[0].Body)
+	}
+	if len(block.Statements) != 2 {
+		t.Fatalf("block does not contain 2 statements. got=%d", len(block.Statements))
+	}
+	if _, ok := exp.Arms[1].Body.(*ast.HashLiteral); !ok {
+		t.Fatalf("exp.Arms[1].Body is not *ast.HashLiteral. got=%T", exp.Arms[1].Body)
+	}
+	if exp.String() != `match (x) {1 => {let y = 2;y}, _ => {a:1}}` {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+}
+
+func TestMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match x { _ => 1 }`, "expected next token to be (, got IDENT instead"},
+		{`match (x) { 1 2 }`, "expected next token to be =>, got INT instead"},
+		{`match (x) { 1 => 2 3 => 4 }`, "expected next token to be ,, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first=%q, got=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -556,6 +823,57 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		minArity int
+	}{
+		{"fn(x, y = 10) {}", "fn(x, y = 10)", 1},
+		{"fn(x = 1, y = x * 2) {}", "fn(x = 1, y = (x * 2))", 0},
+		{"fn(first, ...rest) {}", "fn(first, ...rest)", 1},
+		{"fn(...args) {}", "fn(...args)", 0},
+		{"fn(x, y = 2, ...rest) {}", "fn(x, y = 2, ...rest)", 1},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if got := function.String(); got != tt.expected {
+			t.Errorf("wrong function for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if got := function.MinArity(); got != tt.minArity {
+			t.Errorf("wrong MinArity for %q. want=%d, got=%d", tt.input, tt.minArity, got)
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x = 1, y) {}", "parameter y without a default follows a parameter with one"},
+		{"fn(...rest, x) {}", "expected next token to be ), got , instead"},
+		{"fn(x y) {}", "expected next token to be ,, got IDENT instead"},
+		{"fn(1) {}", "expected next token to be IDENT, got INT instead"},
+		{"fn(...) {}", "expected next token to be IDENT, got ) instead"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first=%q, got=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5)`
 
@@ -602,6 +920,73 @@ func checkParserErrors(t *testing.T, p *parser.Parser) {
 	t.FailNow()
 }
 
+func TestSpreadExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...args)", "f(...args)"},
+		{"f(1, ...a, ...b)", "f(1, ...a, ...b)"},
+		{"[...a, 1 + 2, ...b]", "[...a, (1 + 2), ...b]"},
+		{`{...base, "k": v}`, "{...base, k:v}"},
+		{"{...a, ...f(x)}", "{...a, ...f(x)}"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	p := parser.New(lexer.New(`import "lib/math.mk" as math; math.pi`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Path.Value != "lib/math.mk" || stmt.Name.Value != "math" {
+		t.Errorf("wrong import. got=%q", stmt.String())
+	}
+
+	if got := program.Statements[1].String(); got != "(math[pi])" {
+		t.Errorf("dot access is not an index expression. got=%q", got)
+	}
+}
+
+func TestImportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import math`, "expected next token to be STRING, got IDENT instead"},
+		{`import "math.mk" math`, "expected as after import path, got math instead"},
+		{`import "math.mk" as 1`, "expected next token to be IDENT, got INT instead"},
+		{`math.1`, "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first=%q, got=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world"`
 
@@ -618,6 +1003,67 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestTemplateLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		strings     []string
+		expressions []string
+		str         string
+	}{
+		{"`hello`", []string{"hello"}, nil, "`hello`"},
+		{"`Hello ${name}, you are ${age + 1}`", []string{"Hello ", ", you are ", ""}, []string{"name", "(age + 1)"}, "`Hello ${name}, you are ${(age + 1)}`"},
+		{"`${`${x}`}`", []string{"", ""}, []string{"`${x}`"}, "`${`${x}`}`"},
+		{"`${ {1: 2}[1] }!`", []string{"", "!"}, []string{"({1:2}[1])"}, "`${({1:2}[1])}!`"},
+	}
+
+	for _, tt := range tests {
+		program := setupProgramForTest(t, tt.input)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.TemplateLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.TemplateLiteral. got=%T", stmt.Expression)
+		}
+
+		if !reflect.DeepEqual(literal.Strings, tt.strings) {
+			t.Errorf("wrong strings for %q. want=%q, got=%q", tt.input, tt.strings, literal.Strings)
+		}
+
+		var expressions []string
+		for _, e := range literal.Expressions {
+			expressions = append(expressions, e.String())
+		}
+		if !reflect.DeepEqual(expressions, tt.expressions) {
+			t.Errorf("wrong expressions for %q. want=%q, got=%q", tt.input, tt.expressions, expressions)
+		}
+
+		if literal.String() != tt.str {
+			t.Errorf("wrong String() for %q. want=%q, got=%q", tt.input, tt.str, literal.String())
+		}
+	}
+}
+
+func TestTemplateLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"`a ${x y}`", "expected } after template substitution, got IDENT instead"},
+		{"`a ${x", "expected } after template substitution, got EOF instead"},
+		{"`a ${}`", "no prefix parse function for TEMPLATE_TAIL found"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) == 0 || errs[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. want first=%q, got=%q", tt.input, tt.expected, errs)
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -688,6 +1134,10 @@ func TestParsingHashLiteralWithStringKeys(t *testing.T) {
 
 		testIntegerLiteral(t, v, expectedValue)
 	}
+
+	if hash.String() != "{one:1, two:2, three:3}" {
+		t.Errorf("hash keys are not in source order. got=%q", hash.String())
+	}
 }
 
 func TestParsingEmptyHashLiteralExpressions(t *testing.T) {
@@ -911,3 +1361,22 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	}
 	return true
 }
+
+func FuzzParseProgram(f *testing.F) {
+	f.Add(`let add = fn(x, y) { x + y; }; add(1, 2);`)
+	f.Add(`if (x < y) { x } else { y }`)
+	f.Add(`if (x) { 1 } else if (y) { 2 } else { match (z) { 1 => 2, _ => 3 } }`)
+	f.Add(`let [a, ...b] = [1, 2]; let {c} = {"c": a};`)
+	f.Add(`{"a": [1, 2][0], true: fn() { return null; }}`)
+	f.Add(`let = ; fn(,) { [`)
+	f.Add(`try { throw "a"; } catch (e) { e["message"] } finally { 1 }`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			_ = program.String()
+		}
+	})
+}
@@ -14,29 +14,30 @@ import (
 
 const PROMPT = ">>"
 
-// Start reads from the input source.
+// Start reads from the input source. Programs read their input from in too, and write their output to out.
 func Start(in io.Reader, out io.Writer) error {
-	scanner := bufio.NewScanner(in)
+	// the programs and the prompt share one reader, so a line read by a program is not also run as a program.
+	reader := bufio.NewReader(in)
 	//	env := object.NewEnvironment()
 
 	constants := []object.Object{}
 	globals := make([]object.Object, vm.GlobalSize)
-	symTable := compiler.NewSymbolTable()
-	for i, v := range object.Builtins {
-		symTable.DefineBuiltin(i, v.Name)
-	}
+	builtins := object.NewBuiltinRegistry()
+	builtins.SetStreams(object.NewStreams(reader, out, out))
+	symTable := compiler.NewSymbolTableWithBuiltins(builtins)
 
 	for {
 		_, err := fmt.Fprintf(out, PROMPT)
 		if err != nil {
 			return err
 		}
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := reader.ReadString('\n')
+		if line == "" && err == io.EOF {
 			return nil
 		}
-
-		line := scanner.Text()
+		if err != nil && err != io.EOF {
+			return err
+		}
 		l := lexer.New(line)
 		p := parser.New(l)
 
@@ -46,7 +47,7 @@ func Start(in io.Reader, out io.Writer) error {
 			continue
 		}
 
-		comp := compiler.NewWithState(symTable, constants)
+		comp := compiler.NewWithState(symTable, constants, builtins)
 		err = comp.Compile(program)
 		if err != nil {
 			_, err = fmt.Fprintf(out, "Whoops! Compilation failed:\n %s\n", err)
@@ -19,6 +19,12 @@ const (
 	INT    = "INT"   // 1343456
 	STRING = "STRING"
 
+	// Template literals are split at their ${...} substitutions, whose tokens are lexed in between.
+	TEMPLATE        = "TEMPLATE"        // `text` without substitutions
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"   // `text${
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE" // }text${
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"   // }text`
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -33,6 +39,10 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	ARROW    = "=>"
+	ELLIPSIS = "..."
+	DOT      = "."
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -50,19 +60,33 @@ const (
 	LET      = "LET"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	MATCH    = "MATCH"
+	IMPORT   = "IMPORT"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"null":    NULL,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+	"match":   MATCH,
+	"import":  IMPORT,
 }
 
 // LookupIdent checks the keywords table to see whether a given identifier is a keyword.
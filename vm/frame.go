@@ -11,6 +11,14 @@ type Frame struct {
 	cl          *object.Closure // The compiled function (as a closure) referenced by the frame.
 	ip          int             // the instruction pointer in this frame for this function.
 	basePointer int             // points to the bottom of the stack of the current call frame
+	numArgs     int             // the number of arguments the call passed, before any were packed into a rest parameter
+	handlers    []handler       // error handlers registered by OpTry, innermost last
+}
+
+// handler is an error handler registered by OpTry.
+type handler struct {
+	catchPos int // where execution continues once an error is caught
+	sp       int // the stack pointer when the handler was registered, restored before the error is pushed
 }
 
 // NewFrame returns a new Frame for the given compiled function, with the instruction pointer initialised to -1
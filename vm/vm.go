@@ -5,6 +5,7 @@ import (
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
+	"strings"
 )
 
 const (
@@ -19,14 +20,14 @@ const (
 )
 
 var (
-	// True is an instance of true for the vm. Global variable that is immutable and unique.
-	True = &object.Boolean{Value: true}
+	// True is an instance of true for the vm. Global variable that is immutable and unique, shared with the evaluator.
+	True = object.TRUE
 
-	// False is an instance of false for the vm. Global variable that is immutable and unique.
-	False = &object.Boolean{Value: false}
+	// False is an instance of false for the vm. Global variable that is immutable and unique, shared with the evaluator.
+	False = object.FALSE
 
-	// Null is an instance of null for the vm. Global variable that is immutable and unique.
-	Null = &object.Null{}
+	// Null is an instance of null for the vm. Global variable that is immutable and unique, shared with the evaluator.
+	Null = object.NULL
 )
 
 // VM represents a virtual machine for executing bytecode instructions, managing constants, and handling a stack.
@@ -37,26 +38,39 @@ type VM struct {
 	globals     []object.Object // the VM's storage for all `let` bindings
 	frames      []*Frame
 	framesIndex int
+	builtins    *object.BuiltinRegistry                   // the registry the bytecode was compiled against
+	modules     map[*object.CompiledModule]*object.Module // the modules that have been imported, each run once
 }
 
 // New initializes a new instance of the VM.
 func New(bytecode *compiler.Bytecode) *VM {
+	globals := make([]object.Object, GlobalSize)
+
 	// pre-allocate frames slice
 	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
 	mainClosure := &object.Closure{
-		Fn: mainFn,
+		Fn:      mainFn,
+		Globals: globals,
 	}
 	mainFrame := NewFrame(mainClosure, 0)
 
 	frames := make([]*Frame, MaxFrames)
 	frames[0] = mainFrame
+
+	builtins := bytecode.Builtins
+	if builtins == nil {
+		builtins = object.NewBuiltinRegistry()
+	}
+
 	return &VM{
 		constants:   bytecode.Constants,
 		stack:       make([]object.Object, StackSize),
 		sp:          0,
-		globals:     make([]object.Object, GlobalSize),
+		globals:     globals,
 		frames:      frames,
 		framesIndex: 1, // if we allocate a frame, we have to increase our index for the stack implementation
+		builtins:    builtins,
+		modules:     make(map[*object.CompiledModule]*object.Module),
 	}
 }
 
@@ -64,17 +78,130 @@ func New(bytecode *compiler.Bytecode) *VM {
 func NewWithGlobalStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	vm := New(bytecode)
 	vm.globals = s
+	vm.frames[0].cl.Globals = s
 	return vm
 }
 
 // Run executes the bytecode instructions stored in the VM and manages the stack using provided constants and opcodes.
 func (vm *VM) Run() error {
+	return vm.run(0)
+}
+
+// Streams returns the streams of the registry the bytecode was compiled against, implementing object.CallContext.
+func (vm *VM) Streams() *object.Streams {
+	return vm.builtins.Streams()
+}
+
+// Call synchronously invokes fn with args and returns its result, implementing object.CallContext for builtins.
+// It can also be used by the host to call closures left behind by a finished Run.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		result := fn.Fn(vm, args...)
+		if errObj, ok := result.(*object.Error); ok {
+			return nil, errObj
+		}
+		if result == nil {
+			return Null, nil
+		}
+		return result, nil
+
+	case *object.Closure:
+		sp := vm.sp
+		framesIndex := vm.framesIndex
+
+		result, err := vm.callAndWait(fn, args)
+		if err != nil {
+			// unwind whatever the failed call left behind so the caller's frame can carry on.
+			vm.sp = sp
+			vm.framesIndex = framesIndex
+			return nil, err
+		}
+		return result, nil
+
+	default:
+		return nil, newError("not a function: %s", fn.Type())
+	}
+}
+
+// callAndWait pushes cl and its args as if an OpCall had been executed, then runs until the closure has returned.
+func (vm *VM) callAndWait(cl *object.Closure, args []object.Object) (object.Object, error) {
+	if err := vm.push(cl); err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	depth := vm.framesIndex
+	if err := vm.callClosure(cl, len(args)); err != nil {
+		return nil, err
+	}
+
+	if err := vm.run(depth); err != nil {
+		return nil, err
+	}
+
+	// OpReturnValue and OpReturn leave the result where the closure used to sit on the stack.
+	return vm.pop(), nil
+}
+
+// run executes instructions until the frame stack unwinds back to depth frames or the main frame has no instructions left.
+// A runtime error is handed to the innermost handler above depth; run only fails when there is none.
+func (vm *VM) run(depth int) error {
+	for {
+		err := vm.execute(depth)
+		if err == nil {
+			return nil
+		}
+		if !vm.handleError(err, depth) {
+			return err
+		}
+	}
+}
+
+// handleError looks for a handler for err in the frames above depth, innermost first. Frames without one are
+// popped and recorded in the error's stack. When a handler is found, execution is set up to continue at its catch
+// position with the error, as a hash, on top of the stack.
+func (vm *VM) handleError(err error, depth int) bool {
+	errObj, ok := err.(*object.Error)
+	if !ok {
+		return false
+	}
+
+	for vm.framesIndex > depth {
+		frame := vm.currentFrame()
+		if n := len(frame.handlers); n > 0 {
+			h := frame.handlers[n-1]
+			frame.handlers = frame.handlers[:n-1]
+
+			vm.sp = h.sp
+			frame.ip = h.catchPos - 1
+			// the stack is never higher after restoring sp than it was when the handler was registered.
+			_ = vm.push(errObj.ToHash())
+			return true
+		}
+
+		if vm.framesIndex == 1 {
+			break
+		}
+		vm.popFrame()
+		errObj.Unwind(frame.cl.Fn.Name)
+	}
+
+	return false
+}
+
+// execute runs the instruction loop for run, returning the first runtime error.
+func (vm *VM) execute(depth int) error {
 	var ip int                // current instruction pointer position within the active frame
 	var ins code.Instructions // the raw instruction bytes of the active frame, which contains opcode and operands
 	var op code.Opcode        // the opcode decoded from the current instruction
 
 	// Continue executing as long as the instruction pointer hasn't reached the end of the current frame's instructions.
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
 		// Advance the instruction pointer to the next instruction before decoding.
 		vm.currentFrame().ip++
 
@@ -117,7 +244,7 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -165,13 +292,13 @@ func (vm *VM) Run() error {
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			vm.globals[globalIndex] = vm.pop()
+			vm.currentFrame().cl.Globals[globalIndex] = vm.pop()
 
 		case code.OpGetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
+			err := vm.push(vm.currentFrame().cl.Globals[globalIndex])
 			if err != nil {
 				return err
 			}
@@ -187,6 +314,16 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpConcat:
+			numOfParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			str := vm.buildString(vm.sp-numOfParts, vm.sp)
+			vm.sp = vm.sp - numOfParts
+
+			if err := vm.push(str); err != nil {
+				return err
+			}
+
 		case code.OpHash:
 			numOfElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -221,6 +358,10 @@ func (vm *VM) Run() error {
 		case code.OpReturnValue:
 			// Get fn return val from stack
 			returnVal := vm.pop()
+			if vm.framesIndex == 1 {
+				// a return outside of any function ends the program, leaving its value as the last popped element.
+				return nil
+			}
 			// remove frame from stack
 			frame := vm.popFrame()
 
@@ -259,12 +400,15 @@ func (vm *VM) Run() error {
 			}
 
 		case code.OpGetBuiltin:
-			builtinIndex := code.ReadUint8(ins[ip+1:])
-			vm.currentFrame().ip += 1
+			builtinIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
 
-			definition := object.Builtins[builtinIndex]
+			builtin := vm.builtins.Get(int(builtinIndex))
+			if builtin == nil {
+				return newError("builtin %d is not defined", builtinIndex)
+			}
 
-			if err := vm.push(definition.Builtin); err != nil {
+			if err := vm.push(builtin); err != nil {
 				return err
 			}
 
@@ -304,6 +448,87 @@ func (vm *VM) Run() error {
 			if err := vm.push(currentClosure); err != nil {
 				return err
 			}
+
+		case code.OpTry:
+			catchPos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			frame := vm.currentFrame()
+			frame.handlers = append(frame.handlers, handler{catchPos: catchPos, sp: vm.sp})
+
+		case code.OpEndTry:
+			frame := vm.currentFrame()
+			frame.handlers = frame.handlers[:len(frame.handlers)-1]
+
+		case code.OpThrow:
+			return object.NewThrownError(vm.pop())
+
+		case code.OpSlice:
+			start := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.executeSlice(vm.pop(), start); err != nil {
+				return err
+			}
+
+		case code.OpJumpArgGiven:
+			paramIdx := int(code.ReadUint8(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if paramIdx < vm.currentFrame().numArgs {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpConcatArrays:
+			numOfParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			array, err := vm.concatArrays(vm.sp-numOfParts, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numOfParts
+
+			if err := vm.push(array); err != nil {
+				return err
+			}
+
+		case code.OpMergeHashes:
+			numOfParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			hash, err := vm.mergeHashes(vm.sp-numOfParts, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numOfParts
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpCallSpread:
+			// OpConcatArrays always leaves an array for the arguments.
+			args := vm.pop().(*object.Array)
+			if err := vm.executeSpreadCall(args.Elements); err != nil {
+				return err
+			}
+
+		case code.OpImport:
+			constIdx := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			mod, err := vm.importModule(int(constIdx))
+			if err != nil {
+				return err
+			}
+			if err := vm.push(mod); err != nil {
+				return err
+			}
+
+		case code.OpDup:
+			if err := vm.push(vm.stack[vm.sp-1]); err != nil {
+				return err
+			}
 		}
 
 	}
@@ -324,7 +549,7 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 func (vm *VM) push(o object.Object) error {
 	// return an error if the stack exceeds the predefined StackSize.
 	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+		return newError("stack overflow")
 	}
 
 	vm.stack[vm.sp] = o
@@ -350,10 +575,11 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	switch {
 	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
 		return vm.executeIntegerBinaryOperation(op, left, right)
-	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ,
+		leftType == object.STRING_OBJ && rightType == object.INTEGER_OBJ && op == code.OpMul:
 		return vm.executeStringBinaryOperation(op, left, right)
 	default:
-		return fmt.Errorf("unsupported types for binary operator: %s %s", leftType, rightType)
+		return operatorError(op, left, right)
 	}
 }
 
@@ -364,16 +590,38 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
 		return vm.executeIntegerComparison(op, left, right)
 	}
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		return vm.executeStringComparison(op, left, right)
+	}
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(nativeBoolToBooleanObject(object.Equal(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
+		return vm.push(nativeBoolToBooleanObject(!object.Equal(left, right)))
 	default:
-		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+		return operatorError(op, left, right)
+	}
+}
 
+// operators maps the opcodes of infix operators back to the operator that was written in the source.
+var operators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+// operatorError reports an infix operator that cannot be applied to its operands, worded the same way as the evaluator.
+func operatorError(op code.Opcode, left, right object.Object) error {
+	if left.Type() != right.Type() {
+		return newError("type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
 	}
+	return newError("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
 }
 
 func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
@@ -387,8 +635,29 @@ func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object
 		return vm.push(nativeBoolToBooleanObject(rightVal != leftVal))
 	case code.OpGreaterThan:
 		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
 	default:
-		return fmt.Errorf("unknown operator: %d", op)
+		return newError("unknown operator: %d", op)
+	}
+}
+
+// executeStringComparison compares strings lexicographically, by the characters they contain.
+func (vm *VM) executeStringComparison(op code.Opcode, left, right object.Object) error {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal > rightVal))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftVal < rightVal))
+	default:
+		return newError("unknown operator: %d", op)
 	}
 }
 
@@ -409,7 +678,7 @@ func (vm *VM) executeBangOperator() error {
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 	if operand.Type() != object.INTEGER_OBJ {
-		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+		return newError("unknown operator: -%s", operand.Type())
 	}
 
 	val := operand.(*object.Integer).Value
@@ -432,18 +701,29 @@ func (vm *VM) executeIntegerBinaryOperation(op code.Opcode, left object.Object,
 		result = leftVal * rightVal
 
 	case code.OpDiv:
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		result = leftVal / rightVal
 
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return newError("unknown integer operator: %d", op)
 	}
 
 	return vm.push(&object.Integer{Value: result})
 }
 
 func (vm *VM) executeStringBinaryOperation(op code.Opcode, left object.Object, right object.Object) error {
+	if count, ok := right.(*object.Integer); ok {
+		result := object.RepeatString(left.(*object.String), count.Value)
+		if errObj, ok := result.(*object.Error); ok {
+			return errObj
+		}
+		return vm.push(result)
+	}
+
 	if op != code.OpAdd {
-		return fmt.Errorf("unknown string operator: %d", op)
+		return operatorError(op, left, right)
 	}
 
 	leftValue := left.(*object.String).Value
@@ -463,29 +743,112 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 	return &object.Array{Elements: elements}
 }
 
-// buildHash iterates through elements between startIndex and endIndex in pairs creating a object.HashPair out of them.
-// It generates the HashKey and adds to hashedPairs, then builds the *object.Hash with them.
+// buildString joins the Inspect output of the elements in the specified section of the stack into an *object.String.
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out strings.Builder
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// buildHash iterates through elements between startIndex and endIndex in pairs, adding each key and value to a new
+// *object.Hash in the order they appear on the stack.
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	hashedPairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
 	for i := startIndex; i < endIndex; i += 2 {
 		key := vm.stack[i]
 		value := vm.stack[i+1]
-		pair := object.HashPair{
-			Key:   key,
-			Value: value,
+
+		hashKey, ok := object.AsHashable(key)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", key.Type())
 		}
-		hashKey, ok := key.(object.Hashable)
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+// importModule returns the module compiled into the constant at constIndex, running it the first time it is imported.
+// The module runs like a call to a function without parameters, in a closure holding the module's own global bindings.
+func (vm *VM) importModule(constIndex int) (object.Object, error) {
+	compiled, ok := vm.constants[constIndex].(*object.CompiledModule)
+	if !ok {
+		return nil, newError("not a module: %+v", vm.constants[constIndex])
+	}
+	if mod, ok := vm.modules[compiled]; ok {
+		return mod, nil
+	}
+
+	globals := make([]object.Object, compiled.NumGlobals)
+	cl := &object.Closure{
+		Fn:      &object.CompiledFunction{Instructions: compiled.Instructions, Name: compiled.Name},
+		Globals: globals,
+	}
+
+	sp := vm.sp
+	framesIndex := vm.framesIndex
+	if err := vm.push(cl); err != nil {
+		return nil, err
+	}
+	if err := vm.callClosure(cl, 0); err != nil {
+		return nil, err
+	}
+	if err := vm.run(framesIndex); err != nil {
+		vm.sp = sp
+		vm.framesIndex = framesIndex
+		return nil, err
+	}
+
+	// the module's frame is still there when it ran to the end, rather than returning early.
+	vm.framesIndex = framesIndex
+	vm.sp = sp
+
+	exports := object.NewHash()
+	for _, export := range compiled.Exports {
+		if value := globals[export.Index]; value != nil {
+			exports.Set(&object.String{Value: export.Name}, value)
+		}
+	}
+
+	mod := &object.Module{Name: compiled.Name, Exports: exports}
+	vm.modules[compiled] = mod
+	return mod, nil
+}
+
+// concatArrays joins the arrays between startIndex and endIndex on the stack into a new *object.Array.
+func (vm *VM) concatArrays(startIndex, endIndex int) (object.Object, error) {
+	var elements []object.Object
+	for i := startIndex; i < endIndex; i++ {
+		array, ok := vm.stack[i].(*object.Array)
 		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			return nil, newError("spread element must be ARRAY, got %s", vm.stack[i].Type())
 		}
+		elements = append(elements, array.Elements...)
+	}
 
-		hashedPairs[hashKey.HashKey()] = pair
+	return &object.Array{Elements: elements}, nil
+}
+
+// mergeHashes copies the pairs of the hashes between startIndex and endIndex on the stack into a new *object.Hash,
+// in the order they appear on the stack.
+func (vm *VM) mergeHashes(startIndex, endIndex int) (object.Object, error) {
+	merged := object.NewHash()
+	for i := startIndex; i < endIndex; i++ {
+		hash, ok := vm.stack[i].(*object.Hash)
+		if !ok {
+			return nil, newError("spread element must be HASH, got %s", vm.stack[i].Type())
+		}
+		for _, pair := range hash.Pairs() {
+			merged.Set(pair.Key.(object.Hashable), pair.Value)
+		}
 	}
 
-	return &object.Hash{
-		Pairs: hashedPairs,
-	}, nil
+	return merged, nil
 }
 
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
@@ -493,11 +856,21 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
 
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.push(left.(*object.String).Index(index.(*object.Integer).Value))
+
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
 
+	case left.Type() == object.MODULE_OBJ:
+		value, errObj := left.(*object.Module).Get(index)
+		if errObj != nil {
+			return errObj
+		}
+		return vm.push(value)
+
 	default:
-		return fmt.Errorf("index operator not supported: %s", left.Type())
+		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
@@ -518,17 +891,32 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
 
 	// Check whether the index provided can be used as a hash key
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", index.Type())
+		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return vm.push(Null)
 	}
 
-	return vm.push(pair.Value)
+	return vm.push(value)
+}
+
+// executeSlice pushes the elements of array from start on, as a new array; the rest of a destructuring let.
+func (vm *VM) executeSlice(array object.Object, start int) error {
+	arrayObject, ok := array.(*object.Array)
+	if !ok {
+		return newError("rest element must be ARRAY, got %s", array.Type())
+	}
+
+	var elements []object.Object
+	if start < len(arrayObject.Elements) {
+		elements = append(elements, arrayObject.Elements[start:]...)
+	}
+
+	return vm.push(&object.Array{Elements: elements})
 }
 
 // currentFrame returns the last value of the stack (e.g. peek)
@@ -546,13 +934,41 @@ func (vm *VM) popFrame() *Frame {
 	return vm.frames[vm.framesIndex]
 }
 
+// callClosure calls cl with the numArgs arguments on top of the stack.
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	var rest []object.Object
+	if fn := cl.Fn; fn.Variadic && numArgs > fn.NumParameters {
+		rest = append(rest, vm.stack[vm.sp-numArgs+fn.NumParameters:vm.sp]...)
+		vm.sp -= len(rest)
+	}
+	return vm.enterClosure(cl, numArgs, rest)
+}
+
+// enterClosure pushes the frame for a call of cl with numArgs arguments. The arguments that fill cl's parameters are
+// on top of the stack, and the ones past them are in rest, to be packed into the rest parameter.
+func (vm *VM) enterClosure(cl *object.Closure, numArgs int, rest []object.Object) error {
+	fn := cl.Fn
+	if numArgs < fn.MinParameters || (numArgs > fn.NumParameters && !fn.Variadic) {
+		return object.NewArityError(fn.MinParameters, fn.NumParameters, fn.Variadic, numArgs)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return newError("stack overflow")
+	}
+
+	// Create a new frame for the compiledFn, accounting for the arguments on the stack so we don't move basePointer
+	// too high.
+	frame := NewFrame(cl, vm.sp-(numArgs-len(rest)))
+	frame.numArgs = numArgs
+	if frame.basePointer+fn.NumLocals >= StackSize {
+		return newError("stack overflow")
+	}
+
+	if fn.Variadic {
+		// the rest parameter's slot directly follows the parameters.
+		vm.stack[frame.basePointer+fn.NumParameters] = &object.Array{Elements: rest}
 	}
 
-	// Create a new frame for the compiledFn, accounting for numArgs so we don't move basePointer too high.
-	frame := NewFrame(cl, vm.sp-numArgs)
 	// add the frame to the vm frame stack.
 	vm.pushFrame(frame)
 	// save the value of sp before executing a function
@@ -570,33 +986,70 @@ func (vm *VM) executeCall(numArgs int) error {
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
 	default:
-		return fmt.Errorf("calling non-function and non-built-in")
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+// executeSpreadCall calls the callee on top of the stack with args, the arguments of a call that spreads arrays into
+// them. Only the arguments that fill a closure's parameters go on the stack: builtins take args as they are, and a
+// variadic closure gets the arguments past its parameters packed straight into its rest parameter, so a spread array
+// may be longer than the stack.
+func (vm *VM) executeSpreadCall(args []object.Object) error {
+	switch callee := vm.stack[vm.sp-1].(type) {
+	case *object.Builtin:
+		result := callee.Fn(vm, args...)
+		vm.sp--
+		return vm.pushBuiltinResult(result)
+	case *object.Closure:
+		onStack := args
+		var rest []object.Object
+		if fn := callee.Fn; fn.Variadic && len(args) > fn.NumParameters {
+			onStack = args[:fn.NumParameters]
+			rest = append(rest, args[fn.NumParameters:]...)
+		}
+		for _, arg := range onStack {
+			if err := vm.push(arg); err != nil {
+				return err
+			}
+		}
+		return vm.enterClosure(callee, len(args), rest)
+	default:
+		return newError("not a function: %s", callee.Type())
 	}
 }
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	result := builtin.Fn(args...)
+	result := builtin.Fn(vm, args...)
 	vm.sp = vm.sp - numArgs - 1
 
-	if result != nil {
-		if err := vm.push(result); err != nil {
-			return nil
-		}
-	} else {
-		if err := vm.push(Null); err != nil {
-			return nil
-		}
+	return vm.pushBuiltinResult(result)
+}
+
+// pushBuiltinResult pushes the result of a builtin, once the callee and its arguments are off the stack.
+func (vm *VM) pushBuiltinResult(result object.Object) error {
+	// an error returned by a builtin stops the program, just like an error raised by the VM itself.
+	if errObj, ok := result.(*object.Error); ok {
+		return errObj
 	}
 
-	return nil
+	if result == nil {
+		result = Null
+	}
+
+	return vm.push(result)
+}
+
+// newError returns a runtime error as an *object.Error, the same value the evaluator produces for a failed program.
+func newError(format string, a ...any) error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
 func (vm *VM) pushClosure(constIndex int, freeVariableCount int) error {
 	constant := vm.constants[constIndex]
 	fn, ok := constant.(*object.CompiledFunction)
 	if !ok {
-		return fmt.Errorf("not a function: %+v", constant)
+		return newError("not a function: %+v", constant)
 	}
 
 	freeVariables := make([]object.Object, freeVariableCount)
@@ -608,7 +1061,7 @@ func (vm *VM) pushClosure(constIndex int, freeVariableCount int) error {
 
 	// once we have collected the freeVariables, we pop them off the stack
 	vm.sp = vm.sp - freeVariableCount
-	closure := &object.Closure{Fn: fn, Free: freeVariables}
+	closure := &object.Closure{Fn: fn, Free: freeVariables, Globals: vm.currentFrame().cl.Globals}
 	return vm.push(closure)
 }
 
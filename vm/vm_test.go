@@ -1,6 +1,7 @@
 package vm_test
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/compiler"
@@ -8,6 +9,7 @@ import (
 	"monkey/object"
 	"monkey/parser"
 	"monkey/vm"
+	"strings"
 	"testing"
 )
 
@@ -80,6 +82,16 @@ func TestBooleanExpressions(t *testing.T) {
 		{name: "bang operator treats integers as truthy", input: "!5", expected: false},
 		{name: "double bang converts integers to truthy booleans", input: "!!5", expected: true},
 		{name: "bang operator treats null if results as falsy", input: "!(if (false) { 5; })", expected: true},
+		{name: "strings compare by value", input: `"a" == "a"`, expected: true},
+		{name: "different strings are not equal", input: `"a" != "b"`, expected: true},
+		{name: "arrays compare by their elements", input: "[1, [2, 3]] == [1, [2, 3]]", expected: true},
+		{name: "arrays of different lengths are not equal", input: "[1, 2] == [1]", expected: false},
+		{name: "array elements compare in order", input: "[1, 2] != [2, 1]", expected: true},
+		{name: "hashes compare by their pairs in any order", input: `{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, expected: true},
+		{name: "hashes with different values are not equal", input: `{"a": 1} == {"a": 2}`, expected: false},
+		{name: "null equals null", input: "if (false) { 1 } == if (false) { 2 }", expected: true},
+		{name: "values of different types are not equal", input: `1 == "1"`, expected: false},
+		{name: "functions compare by identity", input: "let f = fn() { 1 }; [f == f, fn() { 1 } == fn() { 1 }] == [true, false]", expected: true},
 	}
 
 	for _, tt := range tests {
@@ -141,6 +153,32 @@ func TestStringExpressions(t *testing.T) {
 			input:    `"mon"+"key"+"banana"`,
 			expected: "monkeybanana",
 		},
+		{name: "string repetition", input: `"ab" * 3`, expected: "ababab"},
+		{name: "string repetition by zero", input: `"ab" * 0`, expected: ""},
+		{name: "negative string repetition", input: `"ab" * -1`, expected: &object.Error{Message: "negative repeat count: -1"}},
+		{name: "integer times string", input: `3 * "ab"`, expected: &object.Error{Message: "type mismatch: INTEGER * STRING"}},
+		{name: "string less than", input: `"apple" < "banana"`, expected: true},
+		{name: "string greater than", input: `"apple" > "banana"`, expected: false},
+		{name: "string indexing counts characters", input: `"héllo"[1]`, expected: "é"},
+		{name: "string index out of range", input: `"abc"[3]`, expected: vm.Null},
+		{name: "negative string index", input: `"abc"[-1]`, expected: vm.Null},
+		{name: "len counts characters", input: `len("héllo")`, expected: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "template without substitutions", input: "`monkey`", expected: "monkey"},
+		{name: "empty template", input: "``", expected: ""},
+		{name: "substitutions use Inspect", input: "let name = \"Jo\"; `Hello ${name}, you are ${30 + 1}: ${[1, true]} ${null}`", expected: "Hello Jo, you are 31: [1, true] null"},
+		{name: "nested templates", input: "`a${`b${1}`}c`", expected: "ab1c"},
+		{name: "errors in substitutions", input: "`${1 + true}`", expected: &object.Error{Message: "type mismatch: INTEGER + BOOLEAN"}},
 	}
 
 	for _, tt := range tests {
@@ -208,6 +246,11 @@ func TestIndexExpressions(t *testing.T) {
 		{name: "hash indexing reads the second existing key", input: "{1: 1, 2: 2}[2]", expected: 2},
 		{name: "hash indexing returns null for missing keys", input: "{1: 1}[0]", expected: vm.Null},
 		{name: "hash indexing returns null for empty hashes", input: "{}[0]", expected: vm.Null},
+		{name: "array keys compare by their elements", input: "let cache = {[1, 2]: 3}; cache[[1, 2]]", expected: 3},
+		{name: "array keys compare in order", input: "{[1, 2]: 3}[[2, 1]]", expected: vm.Null},
+		{name: "nested array keys", input: `{[[1], "a"]: 4}[[[1], "a"]]`, expected: 4},
+		{name: "hash keys ignore pair order", input: `{{"x": 1, "y": 2}: 5}[{"y": 2, "x": 1}]`, expected: 5},
+		{name: "equal array keys share a pair", input: "len(keys({[1]: 1, [1]: 2}))", expected: 1},
 	}
 
 	for _, tt := range tests {
@@ -483,6 +526,16 @@ func TestBuiltinFunctions(t *testing.T) {
 			input:    `len("one", "two")`,
 			expected: &object.Error{Message: "wrong number of arguments. got=2, want=1"},
 		},
+		{name: "type() names the object type", input: `[type(1), type(fn() {}), type(len)]`, expected: []string{"INTEGER", "FUNCTION", "BUILTIN"}},
+		{name: "str() uses Inspect", input: `str([1, true])`, expected: "[1, true]"},
+		{name: "int() parses strings", input: `int("41") + 1`, expected: 42},
+		{name: "invalid int() conversion", input: `int({})`, expected: &object.Error{Message: "cannot convert HASH to INTEGER"}},
+		{name: "bool() follows truthiness", input: `bool(0)`, expected: true},
+		{name: "parse_int() with a base", input: `parse_int("777", 8)`, expected: 511},
+		{name: "invalid parse_int()", input: `parse_int("12a")`, expected: &object.Error{Message: `invalid integer "12a" in base 10`}},
+		{name: "is_function() accepts closures and builtins", input: `is_function(fn() {}) == is_function(len)`, expected: true},
+		{name: "format() substitutes verbs", input: `format("%-3s|%3d|%.1f", "a", 7, 2)`, expected: "a  |  7|2.0"},
+		{name: "format() checks argument types", input: `format("%t", 1)`, expected: &object.Error{Message: "format verb %t does not accept INTEGER"}},
 		//{
 		//	name:     "push(array, 1) does not persist the update",
 		//	input:    `let a = [1]; push(a, 2); a`,
@@ -497,6 +550,485 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestNullLiteral(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "null literal evaluates to null", input: "null", expected: vm.Null},
+		{name: "first of an empty array is null", input: "first([]) == null", expected: true},
+		{name: "last of an empty array is null", input: "last([]) == null", expected: true},
+		{name: "rest of an empty array is null", input: "rest([]) == null", expected: true},
+		{name: "tail of an empty array is null", input: "tail([]) == null", expected: true},
+		{name: "if without a matching branch is null", input: "if (false) { 1 } == null", expected: true},
+		{name: "missing hash keys are null", input: `{"a": 1}["b"] == null`, expected: true},
+		{name: "null is falsy", input: "if (null) { 1 } else { 2 }", expected: 2},
+		{name: "bang operator treats null as falsy", input: "!null", expected: true},
+		{name: "null is not equal to false", input: "null == false", expected: false},
+		{name: "null can be stored and compared", input: "let a = [null]; a[0] != null", expected: false},
+		{name: "puts returns null", input: "puts() == null", expected: true},
+		{name: "print returns null", input: "print() == null", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "array pattern", input: `let [a, b] = [1, 2]; a + b`, expected: 3},
+		{name: "rest collects the remaining elements", input: `let [a, ...rest] = [1, 2, 3]; rest`, expected: []int{2, 3}},
+		{name: "rest alone copies the array", input: `let [...all] = [1, 2]; all`, expected: []int{1, 2}},
+		{name: "rest is empty when nothing is left", input: `let [a, b, ...rest] = [1]; rest`, expected: []int{}},
+		{name: "missing elements are null", input: `let [a, b] = [1]; b`, expected: vm.Null},
+		{name: "hash pattern", input: `let {name, age} = {"name": "Ivan", "age": 30}; name`, expected: "Ivan"},
+		{name: "missing keys are null", input: `let {name} = {}; name`, expected: vm.Null},
+		{name: "destructuring inside functions", input: `let f = fn(pair) { let [x, y] = pair; x * y }; f([6, 7])`, expected: 42},
+		{name: "destructured names are captured by closures", input: `let f = fn(p) { let {n} = p; fn() { n } }; f({"n": 5})()`, expected: 5},
+		{name: "the value is evaluated once", input: `let calls = fn() { [1, 2] }; let [a, b] = calls(); [a, b]`, expected: []int{1, 2}},
+		{name: "array pattern on a non-indexable value", input: `let [a] = 5; a`, expected: &object.Error{Message: "index operator not supported: INTEGER"}},
+		{name: "hash pattern on an array", input: `let {a} = [1]; a`, expected: &object.Error{Message: "index operator not supported: ARRAY"}},
+		{name: "rest of a non-array", input: `let [...r] = {}; r`, expected: &object.Error{Message: "rest element must be ARRAY, got HASH"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "default is used when the argument is missing", input: `let f = fn(x, y = 10) { x + y }; f(1)`, expected: 11},
+		{name: "default is skipped when the argument is given", input: `let f = fn(x, y = 10) { x + y }; f(1, 2)`, expected: 3},
+		{name: "defaults can refer to earlier parameters", input: `let f = fn(x, y = x * 2) { y }; f(4)`, expected: 8},
+		{name: "defaults can refer to the enclosing scope", input: `let n = 5; let f = fn(x = n) { x }; f()`, expected: 5},
+		{name: "defaults are captured by closures", input: `let f = fn(a) { fn(b = a) { b } }; f(7)()`, expected: 7},
+		{name: "defaults cannot see later parameters", input: `let b = 5; fn(a = b, b = 1) { a + 1 }()`, expected: 6},
+		{name: "later parameters are not read before they are set", input: `let b = 5; fn(a = b, b = 1) { [a] }()`, expected: []int{5}},
+		{name: "defaults do not read stale arguments", input: `let b = 5; let f = fn(a = b, b = 1) { a }; f(1, 2); f()`, expected: 5},
+		{name: "defaults cannot see the rest parameter", input: `let r = 5; fn(a = r, ...r) { a }()`, expected: 5},
+		{name: "passing null keeps null", input: `let f = fn(x = 1) { x }; f(null)`, expected: vm.Null},
+		{name: "rest collects the remaining arguments", input: `let f = fn(first, ...rest) { rest }; f(1, 2, 3)`, expected: []int{2, 3}},
+		{name: "rest is empty without extra arguments", input: `let f = fn(first, ...rest) { rest }; f(1)`, expected: []int{}},
+		{name: "defaults and rest together", input: `let f = fn(x, y = 2, ...rest) { [x, y, len(rest)] }; f(1)`, expected: []int{1, 2, 0}},
+		{name: "recursive variadic function", input: `let count = fn(n, ...xs) { if (n == 0) { len(xs) } else { count(n - 1, n, n) } }; count(3)`, expected: 2},
+		{name: "builtins call functions with defaults", input: `map([1, 2], fn(x, y = 10) { x + y })`, expected: []int{11, 12}},
+		{name: "too few arguments", input: `fn(x, y = 1) { x }()`, expected: &object.Error{Message: "wrong number of arguments: want=1..2, got=0"}},
+		{name: "too many arguments", input: `fn(x, y = 1) { x }(1, 2, 3)`, expected: &object.Error{Message: "wrong number of arguments: want=1..2, got=3"}},
+		{name: "too few arguments to a variadic function", input: `fn(x, ...r) { x }()`, expected: &object.Error{Message: "wrong number of arguments: want=1+, got=0"}},
+		{name: "errors in defaults", input: `let f = fn(x = 1 + true) { x }; f()`, expected: &object.Error{Message: "type mismatch: INTEGER + BOOLEAN"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "spread arguments", input: `let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3])`, expected: 6},
+		{name: "spread mixed with arguments", input: `let add = fn(a, b, c) { a + b + c }; add(1, ...[2], 3)`, expected: 6},
+		{name: "spread into a rest parameter", input: `let f = fn(...xs) { xs }; f(...[1, 2], ...[3])`, expected: []int{1, 2, 3}},
+		{name: "spread of an empty array", input: `let f = fn(x = 5) { x }; f(...[])`, expected: 5},
+		{name: "spread into a builtin", input: `len(...["abc"])`, expected: 3},
+		{name: "spread larger than the stack into a rest parameter", input: `let f = fn(a, ...r) { [a, len(r), r[2998]] }; f(...range(3000))`, expected: []int{0, 2999, 2999}},
+		{name: "spread larger than the stack into a builtin", input: `import "math" as m; m.max(...range(3000))`, expected: 2999},
+		{name: "spread into a builtin that calls back", input: `map(...[[1, 2], fn(x) { x * 2 }])`, expected: []int{2, 4}},
+		{name: "spread arguments are counted", input: `fn(a) { a }(...[1, 2])`, expected: &object.Error{Message: "wrong number of arguments: want=1, got=2"}},
+		{name: "array spread", input: `let a = [1, 2]; let b = [4]; [0, ...a, 3, ...b]`, expected: []int{0, 1, 2, 3, 4}},
+		{name: "array spread copies", input: `let a = [1]; let b = [...a]; push(b, 2); a`, expected: []int{1}},
+		{name: "hash spread", input: `let base = {"a": 1, "b": 2}; {...base, "b": 3}["b"]`, expected: 3},
+		{name: "later spreads win", input: `let h = {...{"a": 1}, ...{"a": 2}}; h["a"]`, expected: 2},
+		{name: "spreading a non-array", input: `[...1]`, expected: &object.Error{Message: "spread element must be ARRAY, got INTEGER"}},
+		{name: "spreading a non-hash", input: `{...[1]}`, expected: &object.Error{Message: "spread element must be HASH, got ARRAY"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestElseIfExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "first branch", input: `let x = 1; if (x < 3) { "small" } else if (x < 10) { "medium" } else { "large" }`, expected: "small"},
+		{name: "else if branch", input: `let x = 5; if (x < 3) { "small" } else if (x < 10) { "medium" } else { "large" }`, expected: "medium"},
+		{name: "final else", input: `let x = 50; if (x < 3) { "small" } else if (x < 10) { "medium" } else { "large" }`, expected: "large"},
+		{name: "no branch taken is null", input: `if (false) { 1 } else if (false) { 2 }`, expected: vm.Null},
+		{name: "else if inside functions", input: `let fib = fn(x) { if (x == 0) { 0 } else if (x == 1) { return 1; } else { fib(x - 1) + fib(x - 2) } }; fib(15)`, expected: 610},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "matches an integer", input: `match (1) { 1 => "one", 2 => "two" }`, expected: "one"},
+		{name: "arms are tried in order", input: `match (2) { 1 => "one", 2 => "two", 2 => "again" }`, expected: "two"},
+		{name: "patterns of different types", input: `let f = fn(x) { match (x) { 1 => "int", "a" => "string", true => "bool", _ => "other" } }; [f(1), f("a"), f(true), f(null)]`, expected: []string{"int", "string", "bool", "other"}},
+		{name: "patterns compare structurally", input: `match ([1, {"a": 2}]) { [1, {"a": 2}] => 1, _ => 2 }`, expected: 1},
+		{name: "patterns are expressions", input: `let a = 2; match (4) { a + a => "sum", _ => "none" }`, expected: "sum"},
+		{name: "no matching arm is null", input: `match (3) { 1 => 2 }`, expected: vm.Null},
+		{name: "empty match is null", input: `match (3) { }`, expected: vm.Null},
+		{name: "wildcard matches anything", input: `match ("x") { _ => 7 }`, expected: 7},
+		{name: "match is an expression", input: `let v = match (1) { 1 => 10 } + 1; v`, expected: 11},
+		{name: "match inside recursion", input: `let f = fn(n) { match (n) { 0 => 0, 1 => 1, _ => f(n - 1) + f(n - 2) } }; f(15)`, expected: 610},
+		{name: "later patterns are not evaluated", input: `match (1) { 1 => "one", 1 / 0 => "never" }`, expected: "one"},
+		{name: "errors in the subject are raised", input: `match (1 / 0) { _ => 1 }`, expected: &object.Error{Message: "division by zero"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "try without an error has the value of its block", input: `try { 1 } catch (e) { 2 }`, expected: 1},
+		{name: "a thrown string is the message", input: `try { throw "boom" } catch (e) { e["message"] }`, expected: "boom"},
+		{name: "the thrown value is kept", input: `try { throw [1, 2] } catch (e) { e["value"] }`, expected: []int{1, 2}},
+		{name: "runtime errors can be caught", input: `try { 1 / 0 } catch (e) { e["message"] }`, expected: "division by zero"},
+		{name: "runtime errors have no value", input: `try { -true } catch (e) { e["value"] == null }`, expected: true},
+		{name: "builtin errors can be caught", input: `try { len(1) } catch (e) { e["message"] }`, expected: "argument to `len` not supported, got INTEGER"},
+		{name: "errors unwind through calls", input: `let f = fn() { throw "deep" }; let g = fn() { f() + 1 }; try { g() } catch (e) { e["message"] }`, expected: "deep"},
+		{name: "the stack names the functions unwound", input: `let f = fn() { throw "x" }; let g = fn() { f() }; try { g() } catch (e) { len(e["stack"]) }`, expected: 2},
+		{name: "anonymous functions are listed", input: `try { fn() { throw "x" }() } catch (e) { e["stack"][0] }`, expected: "<anonymous>"},
+		{name: "the stack does not include frames below the handler", input: `let f = fn() { try { throw "x" } catch (e) { e["stack"] } }; let g = fn() { f() }; len(g())`, expected: 0},
+		{name: "the stack survives a rethrow", input: `let f = fn() { throw "x" }; try { try { f() } catch (e) { throw e } } catch (e) { e["stack"][0] }`, expected: "f"},
+		{name: "execution continues after a caught error", input: `let a = try { throw "x" } catch (e) { 1 }; a + 1`, expected: 2},
+		{name: "stack overflows can be caught", input: `let r = fn(n) { r(n + 1) }; try { r(0) } catch (e) { e["message"] }`, expected: "stack overflow"},
+		{name: "callbacks can throw through builtins", input: `try { map([1], fn(x) { throw x + 1 }) } catch (e) { e["value"] }`, expected: 2},
+		{name: "callbacks can catch their own errors", input: `map([1, 2], fn(x) { try { throw x } catch (e) { e["value"] * 10 } })`, expected: []int{10, 20}},
+		{name: "finally runs after the block", input: `let f = fn() { try { 1 } finally { 2 } }; f()`, expected: 1},
+		{name: "finally without catch rethrows", input: `try { throw "x" } finally { 2 }`, expected: &object.Error{Message: "x"}},
+		{name: "finally runs when the catch block throws", input: `try { try { throw "a" } catch (e) { throw "b" } finally { 1 } } catch (e) { e["message"] }`, expected: "b"},
+		{name: "return inside try runs finally", input: `let f = fn(a) { try { return a } finally { a } }; f(3)`, expected: 3},
+		{name: "return inside finally wins", input: `let f = fn() { try { return 1 } finally { return 2 } }; f()`, expected: 2},
+		{name: "errors in finally win", input: `try { try { 1 } finally { throw "late" } } catch (e) { e["message"] }`, expected: "late"},
+		{name: "return inside catch leaves the function", input: `let f = fn() { try { throw "x" } catch (e) { return 5 }; 6 }; f()`, expected: 5},
+		{name: "uncaught throws stop the program", input: `throw "boom"; 1`, expected: &object.Error{Message: "boom"}},
+		{name: "thrown hashes with a message keep it", input: `try { throw {"message": "m", "code": 7} } catch (e) { e["value"]["code"] }`, expected: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestFinallyRuns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`try { record("try") } finally { record("finally") }`, []string{"try", "finally"}},
+		{`try { throw "x" } catch (e) { record("catch") } finally { record("finally") }`, []string{"catch", "finally"}},
+		{`try { try { throw "x" } finally { record("inner") } } catch (e) { record("outer") }`, []string{"inner", "outer"}},
+		{`let f = fn() { try { return record("return") } finally { record("finally") } }; f()`, []string{"return", "finally"}},
+		{`let f = fn() { try { try { return 1 } finally { record("inner") } } finally { record("outer") } }; f()`, []string{"inner", "outer"}},
+		{`let f = fn() { try { throw "x" } catch (e) { return 1 } finally { record("finally") } }; f()`, []string{"finally"}},
+	}
+
+	for _, tt := range tests {
+		var recorded []string
+		builtins := object.NewBuiltinRegistry()
+		builtins.Register("record", func(_ object.CallContext, args ...object.Object) object.Object {
+			recorded = append(recorded, args[0].Inspect())
+			return args[0]
+		})
+
+		program := parse(tt.input)
+		comp := compiler.NewWithBuiltins(builtins)
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		if err := vm.New(comp.Bytecode()).Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if fmt.Sprint(recorded) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong blocks ran for %q. want=%v, got=%v", tt.input, tt.expected, recorded)
+		}
+	}
+}
+
+func TestCustomBuiltins(t *testing.T) {
+	builtins := object.NewBuiltinRegistry()
+	builtins.Register("double", func(_ object.CallContext, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	builtins.Register("len", func(_ object.CallContext, args ...object.Object) object.Object {
+		return &object.String{Value: "replaced"}
+	})
+	builtins.Remove("puts")
+
+	program := parse(`[double(21), len([])]`)
+	comp := compiler.NewWithBuiltins(builtins)
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	virtualMachine := vm.New(comp.Bytecode())
+	if err := virtualMachine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	result, ok := virtualMachine.LastPoppedStackElem().(*object.Array)
+	if !ok || len(result.Elements) != 2 {
+		t.Fatalf("object is not a two element Array: %+v", virtualMachine.LastPoppedStackElem())
+	}
+	testExpectedObject(t, 42, result.Elements[0])
+	testExpectedObject(t, "replaced", result.Elements[1])
+
+	if err := compiler.NewWithBuiltins(builtins).Compile(parse(`puts(1)`)); err == nil {
+		t.Errorf("expected removed builtin puts to be undefined")
+	}
+
+	if err := compiler.New().Compile(parse(`double(1)`)); err == nil {
+		t.Errorf("expected double to be undefined with the default builtins")
+	}
+}
+
+func TestManyBuiltins(t *testing.T) {
+	// indexes past 255 need both bytes of the OpGetBuiltin operand. Identifiers cannot contain digits, so builtin i
+	// is named after its digits spelled as the letters a to j: builtin 260 is b_cga.
+	name := func(i int) string {
+		return "b_" + strings.Map(func(r rune) rune { return r - '0' + 'a' }, fmt.Sprint(i))
+	}
+
+	builtins := object.NewBuiltinRegistry()
+	for i := len(builtins.Definitions()); i < 300; i++ {
+		value := int64(i)
+		builtins.Register(name(i), func(_ object.CallContext, _ ...object.Object) object.Object {
+			return &object.Integer{Value: value}
+		})
+	}
+
+	input := fmt.Sprintf("[%s(), %s()]", name(260), name(299))
+	runVmTestWithBuiltins(t, vmTestCase{input: input, expected: []int{260, 299}}, builtins)
+}
+
+// callbackBuiltins returns a registry with an `apply` builtin that calls its first argument with the remaining ones.
+func callbackBuiltins() *object.BuiltinRegistry {
+	builtins := object.NewBuiltinRegistry()
+	builtins.Register("apply", func(ctx object.CallContext, args ...object.Object) object.Object {
+		result, err := ctx.Call(args[0], args[1:]...)
+		if err != nil {
+			return &object.Error{Message: "apply: " + err.Error()}
+		}
+		return result
+	})
+	return builtins
+}
+
+func TestBuiltinCallbacks(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			name:     "builtin calls a closure with arguments",
+			input:    `apply(fn(a, b) { a * b }, 6, 7)`,
+			expected: 42,
+		},
+		{
+			name:     "builtin calls a closure capturing free variables",
+			input:    `let n = 10; let add = fn(x) { fn(y) { x + y + n } }; apply(add(1), 2)`,
+			expected: 13,
+		},
+		{
+			name:     "builtin calls another builtin",
+			input:    `apply(len, [1, 2, 3])`,
+			expected: 3,
+		},
+		{
+			name:     "callbacks can call builtins that call back again",
+			input:    `apply(fn(x) { apply(fn(y) { y * 2 }, x) + 1 }, 20)`,
+			expected: 41,
+		},
+		{
+			name:     "execution carries on in the caller after a callback returns",
+			input:    `let f = fn() { let a = 1; let b = apply(fn() { 2 }); a + b }; f() + f()`,
+			expected: 6,
+		},
+		{
+			name:     "recursive closures can be called from builtins",
+			input:    `let fact = fn(n) { if (n == 0) { 1 } else { n * apply(fact, n - 1) } }; fact(5)`,
+			expected: 120,
+		},
+		{
+			name:     "an error returned by the builtin stops the program",
+			input:    `let r = apply(fn(a) { a }); 1`,
+			expected: &object.Error{Message: "apply: wrong number of arguments: want=1, got=0"},
+		},
+		{
+			name:     "errors from callbacks become the builtin result",
+			input:    `apply(fn(a) { a })`,
+			expected: &object.Error{Message: "apply: wrong number of arguments: want=1, got=0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTestWithBuiltins(t, tt, callbackBuiltins())
+		})
+	}
+}
+
+func TestUnboundedRecursionOverflows(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let f = fn(x) { f(x) }; f(1)`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	err := vm.New(comp.Bytecode()).Run()
+	if err == nil || err.Error() != "stack overflow" {
+		t.Fatalf("expected stack overflow error, got=%v", err)
+	}
+}
+
+func TestCallClosureAfterRun(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let base = 40; fn(x) { base + x }`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	virtualMachine := vm.New(comp.Bytecode())
+	if err := virtualMachine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	result, err := virtualMachine.Call(virtualMachine.LastPoppedStackElem(), &object.Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("call error: %s", err)
+	}
+	testExpectedObject(t, 42, result)
+
+	if _, err := virtualMachine.Call(&object.Integer{Value: 1}); err == nil {
+		t.Errorf("expected error calling a non-function")
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "map transforms each element", input: `map([1, 2, 3], fn(x) { x * 2 })`, expected: []int{2, 4, 6}},
+		{name: "map accepts builtins as callbacks", input: `map([[1], [1, 2]], len)`, expected: []int{1, 2}},
+		{name: "map over an empty array", input: `map([], fn(x) { x })`, expected: []int{}},
+		{name: "filter keeps truthy results", input: `filter([1, 2, 3, 4], fn(x) { x > 2 })`, expected: []int{3, 4}},
+		{name: "reduce with an initial value", input: `reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, expected: 16},
+		{name: "reduce seeds with the first element", input: `reduce([1, 2, 3], fn(acc, x) { acc * x })`, expected: 6},
+		{name: "each returns null", input: `each([1, 2], fn(x) { x })`, expected: vm.Null},
+		{name: "any finds a match", input: `any([1, 2, 3], fn(x) { x == 2 })`, expected: true},
+		{name: "any on an empty array", input: `any([], fn(x) { true })`, expected: false},
+		{name: "all detects a mismatch", input: `all([1, 2, 3], fn(x) { x < 3 })`, expected: false},
+		{name: "all on an empty array", input: `all([], fn(x) { false })`, expected: true},
+		{name: "find returns the first match", input: `find([1, 2, 3, 4], fn(x) { x > 1 })`, expected: 2},
+		{name: "find returns null without a match", input: `find([1], fn(x) { x > 1 })`, expected: vm.Null},
+		{name: "sort orders integers", input: `sort([3, 1, 2])`, expected: []int{1, 2, 3}},
+		{name: "sort orders strings", input: `sort(["b", "c", "a"])[0]`, expected: "a"},
+		{name: "sort does not modify its argument", input: `let a = [2, 1]; sort(a); a`, expected: []int{2, 1}},
+		{name: "sort rejects mixed types", input: `sort([1, "a"])`, expected: &object.Error{Message: "cannot compare STRING and INTEGER"}},
+		{name: "sort_by orders by the computed key", input: `sort_by([3, 1, 2], fn(x) { -x })`, expected: []int{3, 2, 1}},
+		{name: "sort_by is stable", input: `map(sort_by([[1, 2], [0, 1], [1, 1]], fn(p) { p[0] }), fn(p) { p[1] })`, expected: []int{1, 2, 1}},
+		{name: "zip pairs elements up to the shortest array", input: `map(zip([1, 2, 3], [4, 5]), fn(p) { p[0] * p[1] })`, expected: []int{4, 10}},
+		{name: "flatten removes one level of nesting", input: `len(flatten([[1, 2], 3, [[4]]]))`, expected: 4},
+		{name: "range up to an end", input: `range(4)`, expected: []int{0, 1, 2, 3}},
+		{name: "range between bounds", input: `range(2, 5)`, expected: []int{2, 3, 4}},
+		{name: "range with a negative step", input: `range(5, 0, -2)`, expected: []int{5, 3, 1}},
+		{name: "range rejects a zero step", input: `range(0, 5, 0)`, expected: &object.Error{Message: "`range` step must not be zero"}},
+		{name: "reverse returns a reversed copy", input: `reverse([1, 2, 3])`, expected: []int{3, 2, 1}},
+		{name: "slice between two indexes", input: `slice([1, 2, 3, 4], 1, 3)`, expected: []int{2, 3}},
+		{name: "slice to the end", input: `slice([1, 2, 3, 4], 2)`, expected: []int{3, 4}},
+		{name: "slice counts negative indexes from the end", input: `slice([1, 2, 3, 4], -3, -1)`, expected: []int{2, 3}},
+		{name: "slice clamps out of range indexes", input: `slice([1, 2], 5, 10)`, expected: []int{}},
+		{name: "index_of finds a value", input: `index_of(["a", "b"], "b")`, expected: 1},
+		{name: "index_of returns -1 when missing", input: `index_of([1, 2], 3)`, expected: -1},
+		{name: "contains finds a value", input: `contains([1, 2, 3], 3)`, expected: true},
+		{name: "contains returns false when missing", input: `contains([1, 2, 3], "3")`, expected: false},
+		{name: "callback errors are reported", input: `map([1], fn(x, y) { x })`, expected: &object.Error{Message: "wrong number of arguments: want=2, got=1"}},
+		{name: "non-array arguments are rejected", input: `filter(1, fn(x) { x })`, expected: &object.Error{Message: "argument to `filter` must be ARRAY, got INTEGER"}},
+		{name: "callbacks close over script state", input: `let n = 3; let addN = fn(xs) { map(xs, fn(x) { x + n }) }; addN([1, 2])`, expected: []int{4, 5}},
+		{name: "deep recursion through callbacks", input: `let sum = fn(xs) { if (len(xs) == 0) { 0 } else { reduce(xs, fn(a, b) { a + b }) } }; sum(range(1000))`, expected: 499500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{name: "keys follow insertion order", input: `keys({3: "c", 1: "a", 2: "b"})`, expected: []int{3, 1, 2}},
+		{name: "values follow insertion order", input: `values({"b": 2, "a": 1, "c": 3})`, expected: []int{2, 1, 3}},
+		{name: "keys of an empty hash", input: `keys({})`, expected: []int{}},
+		{name: "entries pairs keys with values", input: `map(entries({2: 20, 1: 10}), fn(e) { e[0] + e[1] })`, expected: []int{22, 11}},
+		{name: "has finds a key", input: `has({"a": 1}, "a")`, expected: true},
+		{name: "has reports a missing key", input: `has({"a": 1}, "b")`, expected: false},
+		{name: "has rejects unhashable keys", input: `has({}, [len])`, expected: &object.Error{Message: "unusable as hash key: ARRAY"}},
+		{name: "delete removes a key", input: `keys(delete({1: 1, 2: 2}, 1))`, expected: []int{2}},
+		{name: "delete does not modify its argument", input: `let h = {1: 1}; delete(h, 1); len(keys(h))`, expected: 1},
+		{name: "merge combines hashes", input: `merge({1: 1}, {2: 2}, {3: 3})`, expected: map[object.HashKey]int64{
+			(&object.Integer{Value: 1}).HashKey(): 1,
+			(&object.Integer{Value: 2}).HashKey(): 2,
+			(&object.Integer{Value: 3}).HashKey(): 3,
+		}},
+		{name: "merge prefers later hashes", input: `merge({"a": 1}, {"a": 2})["a"]`, expected: 2},
+		{name: "merge rejects non-hash arguments", input: `merge({}, [])`, expected: &object.Error{Message: "argument to `merge` must be HASH, got ARRAY"}},
+		{name: "keys rejects non-hash arguments", input: `keys([1])`, expected: &object.Error{Message: "argument to `keys` must be HASH, got ARRAY"}},
+		{name: "each iterates over hashes", input: `each({1: 2}, fn(k, v) { k + v })`, expected: vm.Null},
+		{name: "each over a hash passes key and value", input: `each({1: 2}, fn(k) { k })`, expected: &object.Error{Message: "wrong number of arguments: want=1, got=2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runVmTest(t, tt)
+		})
+	}
+}
+
+func TestHashInspectFollowsInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, `{b: 1, a: 2, 3: 3, true: 4}`},
+		{`{2: 1, 1: 2, 2: 3}`, `{2: 3, 1: 2}`},
+		{`merge({"z": 1, "y": 2}, {"x": 3, "z": 4})`, `{z: 4, y: 2, x: 3}`},
+		{`delete({"c": 1, "b": 2, "a": 3}, "b")`, `{c: 1, a: 3}`},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		for i := 0; i < 10; i++ {
+			machine := vm.New(comp.Bytecode())
+			if err := machine.Run(); err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+
+			if got := machine.LastPoppedStackElem().Inspect(); got != tt.expected {
+				t.Fatalf("wrong inspect output for %s. want=%q, got=%q", tt.input, tt.expected, got)
+			}
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -674,10 +1206,15 @@ func TestRecursiveFibonacci(t *testing.T) {
 }
 
 func runVmTest(t *testing.T, testCase vmTestCase) {
+	t.Helper()
+	runVmTestWithBuiltins(t, testCase, object.NewBuiltinRegistry())
+}
+
+func runVmTestWithBuiltins(t *testing.T, testCase vmTestCase, builtins *object.BuiltinRegistry) {
 	t.Helper()
 	program := parse(testCase.input)
 
-	comp := compiler.New()
+	comp := compiler.NewWithBuiltins(builtins)
 	err := comp.Compile(program)
 	if err != nil {
 		t.Fatalf("compiler error: %s", err)
@@ -685,6 +1222,14 @@ func runVmTest(t *testing.T, testCase vmTestCase) {
 
 	virtualMachine := vm.New(comp.Bytecode())
 	err = virtualMachine.Run()
+	if _, wantErr := testCase.expected.(*object.Error); wantErr {
+		var errObj *object.Error
+		if !errors.As(err, &errObj) {
+			t.Fatalf("expected runtime error, got err=%v", err)
+		}
+		testExpectedObject(t, testCase.expected, errObj)
+		return
+	}
 	if err != nil {
 		t.Fatalf("vm error: %s", err)
 	}
@@ -736,6 +1281,26 @@ func testExpectedObject(t *testing.T, expected any, actual object.Object) {
 			}
 		}
 
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d",
+				len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testStringObject(expectedElem, array.Elements[i])
+			if err != nil {
+				t.Errorf("testStringObject failed: %s", err)
+			}
+		}
+
 	case map[object.HashKey]int64:
 		hash, ok := actual.(*object.Hash)
 		if !ok {
@@ -743,14 +1308,19 @@ func testExpectedObject(t *testing.T, expected any, actual object.Object) {
 			return
 		}
 
-		if len(hash.Pairs) != len(expected) {
+		if hash.Len() != len(expected) {
 			t.Errorf("hash has wrong number of Pairs. want=%d, got=%d",
-				len(expected), len(hash.Pairs))
+				len(expected), hash.Len())
 			return
 		}
 
+		pairs := make(map[object.HashKey]object.HashPair)
+		for _, pair := range hash.Pairs() {
+			pairs[pair.Key.(object.Hashable).HashKey()] = pair
+		}
+
 		for expectedKey, expectedValue := range expected {
-			pair, ok := hash.Pairs[expectedKey]
+			pair, ok := pairs[expectedKey]
 			if !ok {
 				t.Errorf("no pair for given key in Pairs")
 			}
@@ -775,6 +1345,9 @@ func testExpectedObject(t *testing.T, expected any, actual object.Object) {
 		if errorObject.Message != expected.Message {
 			t.Errorf("wrong error message. expected=%q, got=%q", expected.Message, errorObject.Message)
 		}
+
+	default:
+		t.Fatalf("unsupported expected type %T", expected)
 	}
 }
 